@@ -0,0 +1,145 @@
+// Command mqbench is a load generator for the message queue in package
+// pkg/queue. It runs producers and consumers against a local Queue and
+// reports the throughput and the latency percentiles of the messages.
+//
+// The latency of a message is the time from its LogAppendTime until a
+// consumer reads it.
+//
+// Usage:
+//
+//	mqbench [-producers n] [-consumers n] [-messages n] [-size bytes] [-batch n]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/VillePuuska/Message-queue/pkg/queue"
+)
+
+func main() {
+	producers := flag.Int("producers", 4, "number of producer goroutines")
+	consumers := flag.Int("consumers", 4, "number of consumer goroutines")
+	messages := flag.Int("messages", 1000000, "total number of messages to produce")
+	size := flag.Int("size", 128, "size of each message in bytes")
+	batch := flag.Int("batch", 1, "number of messages per AddMany and ReadMany call")
+	flag.Parse()
+
+	if *producers <= 0 || *consumers <= 0 || *messages <= 0 || *size < 0 || *batch <= 0 {
+		fmt.Fprintln(os.Stderr, "mqbench: producers, consumers, messages, and batch must be positive and size non-negative")
+		os.Exit(2)
+	}
+
+	res := run(*producers, *consumers, *messages, *size, *batch)
+	res.print(os.Stdout)
+}
+
+// Results of a benchmark run.
+type result struct {
+	messages  int
+	elapsed   time.Duration
+	latencies []time.Duration
+}
+
+// Runs producers and consumers against a new Queue until all messages
+// have been produced and consumed.
+func run(producers, consumers, messages, size, batch int) result {
+	q := queue.NewQueue[[]byte]()
+	payload := make([]byte, size)
+
+	start := time.Now()
+	var producerWg sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		count := messages / producers
+		if i < messages%producers {
+			count++
+		}
+		producerWg.Add(1)
+		go func() {
+			defer producerWg.Done()
+			produce(q, payload, count, batch)
+		}()
+	}
+
+	latencies := make([][]time.Duration, consumers)
+	var consumerWg sync.WaitGroup
+	for i := 0; i < consumers; i++ {
+		consumerWg.Add(1)
+		go func(index int) {
+			defer consumerWg.Done()
+			latencies[index] = consume(q, batch)
+		}(i)
+	}
+
+	// Once all messages have been produced, Drain closes the Queue and
+	// waits for the consumers to read the rest.
+	producerWg.Wait()
+	_ = q.Drain(context.Background())
+	consumerWg.Wait()
+
+	res := result{
+		messages: messages,
+		elapsed:  time.Since(start),
+	}
+	for _, l := range latencies {
+		res.latencies = append(res.latencies, l...)
+	}
+	slices.Sort(res.latencies)
+	return res
+}
+
+// Adds count messages of payload to q in batches of batch messages.
+func produce(q *queue.Queue[[]byte], payload []byte, count, batch int) {
+	vals := make([][]byte, batch)
+	for i := range vals {
+		vals[i] = payload
+	}
+	for count > 0 {
+		n := min(batch, count)
+		_ = q.AddMany(vals[:n])
+		count -= n
+	}
+}
+
+// Reads messages from q in batches of at most batch messages until q is
+// closed and empty. Returns the latencies of the messages read.
+func consume(q *queue.Queue[[]byte], batch int) []time.Duration {
+	var latencies []time.Duration
+	for {
+		msgs, err := q.ReadManyWait(context.Background(), batch)
+		if err != nil {
+			return latencies
+		}
+		now := time.Now()
+		for _, msg := range msgs {
+			latencies = append(latencies, now.Sub(msg.LogAppendTime))
+		}
+	}
+}
+
+// Returns the p-th percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(p / 100 * float64(len(sorted)-1))
+	return sorted[index]
+}
+
+// Prints the throughput and latency percentiles of the run.
+func (res result) print(w io.Writer) {
+	throughput := float64(res.messages) / res.elapsed.Seconds()
+	fmt.Fprintf(w, "%-12s%d\n", "messages:", res.messages)
+	fmt.Fprintf(w, "%-12s%v\n", "elapsed:", res.elapsed)
+	fmt.Fprintf(w, "%-12s%.0f msg/s\n", "throughput:", throughput)
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		fmt.Fprintf(w, "%-12s%v\n", fmt.Sprintf("p%v:", p), percentile(res.latencies, p))
+	}
+	fmt.Fprintf(w, "%-12s%v\n", "max:", percentile(res.latencies, 100))
+}
@@ -0,0 +1,82 @@
+package testutil
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a clock whose time only changes when told to.
+// FakeClock methods are safe to use concurrently in multiple goroutines.
+type FakeClock struct {
+	now     time.Time
+	tickers []*fakeTicker
+	mu      sync.Mutex
+}
+
+// Ticker of a FakeClock. Like a time.Ticker, it drops ticks that the
+// receiver is too slow to receive.
+type fakeTicker struct {
+	c    chan time.Time
+	d    time.Duration
+	next time.Time
+}
+
+// Function to create a new FakeClock showing the time now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Returns the current time of the FakeClock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Returns a channel that receives the time of the FakeClock every time it
+// is advanced past a multiple of d, and a function to stop the ticker.
+// Panics if d is not positive, like time.NewTicker.
+func (c *FakeClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ticker := &fakeTicker{c: make(chan time.Time, 1), d: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, ticker)
+	stop := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i, t := range c.tickers {
+			if t == ticker {
+				c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+				return
+			}
+		}
+	}
+	return ticker.c, stop
+}
+
+// Moves the time of the FakeClock forward by d and fires the tickers
+// that are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, ticker := range c.tickers {
+		if ticker.next.After(c.now) {
+			continue
+		}
+		select {
+		case ticker.c <- c.now:
+		default:
+		}
+		// Skip the ticks that would have been dropped anyway.
+		ticker.next = ticker.next.Add((c.now.Sub(ticker.next)/ticker.d + 1) * ticker.d)
+	}
+}
@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Message sizes in bytes used in the benchmarks.
+var benchSizes = []int{16, 1024, 64 * 1024}
+
+// Numbers of goroutines per CPU used in the concurrent benchmarks.
+var benchParallelism = []int{1, 4, 16}
+
+// Number of messages per call in the AddMany and ReadMany benchmarks.
+const benchBatch = 100
+
+// Runs f as a sub-benchmark for every combination of message size and parallelism.
+func runBenchmarks(b *testing.B, f func(b *testing.B, payload []byte)) {
+	for _, size := range benchSizes {
+		payload := make([]byte, size)
+		for _, parallelism := range benchParallelism {
+			b.Run(fmt.Sprintf("size=%d/parallelism=%d", size, parallelism), func(b *testing.B) {
+				b.SetParallelism(parallelism)
+				b.ReportAllocs()
+				f(b, payload)
+			})
+		}
+	}
+}
+
+// Returns a Queue with n messages of payload.
+func prefilledQueue(n int, payload []byte) *Queue[[]byte] {
+	q := NewQueue[[]byte]()
+	vals := make([][]byte, n)
+	for i := range vals {
+		vals[i] = payload
+	}
+	_ = q.AddMany(vals)
+	return q
+}
+
+func BenchmarkAdd(b *testing.B) {
+	runBenchmarks(b, func(b *testing.B, payload []byte) {
+		q := NewQueue[[]byte]()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = q.Add(payload)
+			}
+		})
+	})
+}
+
+func BenchmarkAddMany(b *testing.B) {
+	runBenchmarks(b, func(b *testing.B, payload []byte) {
+		q := NewQueue[[]byte]()
+		vals := make([][]byte, benchBatch)
+		for i := range vals {
+			vals[i] = payload
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = q.AddMany(vals)
+			}
+		})
+	})
+}
+
+func BenchmarkRead(b *testing.B) {
+	runBenchmarks(b, func(b *testing.B, payload []byte) {
+		q := prefilledQueue(b.N, payload)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = q.Read()
+			}
+		})
+	})
+}
+
+func BenchmarkReadMany(b *testing.B) {
+	runBenchmarks(b, func(b *testing.B, payload []byte) {
+		q := prefilledQueue(b.N*benchBatch, payload)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = q.ReadMany(benchBatch)
+			}
+		})
+	})
+}
+
+func BenchmarkPeekNext(b *testing.B) {
+	runBenchmarks(b, func(b *testing.B, payload []byte) {
+		q := prefilledQueue(1, payload)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = q.PeekNext()
+			}
+		})
+	})
+}
+
+// Benchmark of Cleanup removing benchBatch messages per call.
+func BenchmarkCleanup(b *testing.B) {
+	for _, size := range benchSizes {
+		payload := make([]byte, size)
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			config, _ := DefaultConfig().WithRetentionCount(benchBatch)
+			q := NewQueueWithConfig[[]byte](config)
+			vals := make([][]byte, benchBatch)
+			for i := range vals {
+				vals[i] = payload
+			}
+			_ = q.AddMany(vals)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				_ = q.AddMany(vals)
+				b.StartTimer()
+				_, _ = q.Cleanup()
+			}
+		})
+	}
+}
+
+// Benchmark with equal numbers of goroutines adding and reading messages
+// concurrently. Measures the time per message from Add to Read.
+func BenchmarkProducersAndConsumers(b *testing.B) {
+	for _, goroutines := range []int{2, 8, 64} {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			q := NewQueue[int]()
+			pairs := goroutines / 2
+			ctx := context.Background()
+			var wg sync.WaitGroup
+
+			b.ResetTimer()
+			for i := 0; i < pairs; i++ {
+				count := b.N / pairs
+				if i < b.N%pairs {
+					count++
+				}
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < count; j++ {
+						_ = q.Add(j)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					for j := 0; j < count; j++ {
+						_, _ = q.ReadWait(ctx)
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"slices"
+	"sync"
+)
+
+// Broker[T] is a registry of Queue[T]s identified by their names.
+// The name of a Queue is the name in its QueueConfig.
+// Broker methods are safe to use concurrently in multiple goroutines.
+//
+// NOTE: never create a Broker directly; use NewBroker[T]() instead
+// to construct a Broker[T].
+type Broker[T any] struct {
+	queues map[string]*Queue[T]
+	mu     sync.Mutex
+}
+
+// Function to initialize a new Broker with no Queues.
+// To create a Broker for Queues of type T, call NewBroker[T]().
+func NewBroker[T any]() *Broker[T] {
+	b := Broker[T]{
+		queues: make(map[string]*Queue[T]),
+	}
+	return &b
+}
+
+// Method to create a new Queue with the given config in the Broker.
+//
+// If the name in config is empty or a parameter of config is invalid,
+// returns the error ErrInvalidConfig.
+// If the Broker already has a Queue with the same name, returns the
+// error ErrQueueExists.
+func (b *Broker[T]) CreateQueue(config QueueConfig) (*Queue[T], error) {
+	if config.name == "" {
+		return nil, ErrInvalidConfig
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.queues[config.name]; ok {
+		return nil, ErrQueueExists
+	}
+	q := NewQueueWithConfig[T](config)
+	b.queues[config.name] = q
+	return q, nil
+}
+
+// Method to get the Queue with the given name.
+//
+// If the Broker has no Queue with the name, returns the error ErrQueueNotFound.
+func (b *Broker[T]) GetQueue(name string) (*Queue[T], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[name]
+	if !ok {
+		return nil, ErrQueueNotFound
+	}
+	return q, nil
+}
+
+// Method to remove the Queue with the given name from the Broker.
+// The removed Queue is closed.
+//
+// If the Broker has no Queue with the name, returns the error ErrQueueNotFound.
+func (b *Broker[T]) DeleteQueue(name string) error {
+	b.mu.Lock()
+	q, ok := b.queues[name]
+	delete(b.queues, name)
+	b.mu.Unlock()
+
+	if !ok {
+		return ErrQueueNotFound
+	}
+	return q.Close()
+}
+
+// Returns the names of all Queues in the Broker in sorted order.
+func (b *Broker[T]) ListQueues() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.listQueuesNoLock()
+}
+
+// Internal method to get the names of all Queues in the Broker in sorted order.
+// Does not lock the Broker; assumes that mu is already held when this
+// function is called.
+func (b *Broker[T]) listQueuesNoLock() []string {
+	names := make([]string, 0, len(b.queues))
+	for name := range b.queues {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Returns the statistics of all Queues in the Broker sorted by name.
+func (b *Broker[T]) Stats() []Stats {
+	b.mu.Lock()
+	queues := make([]*Queue[T], 0, len(b.queues))
+	for _, name := range b.listQueuesNoLock() {
+		queues = append(queues, b.queues[name])
+	}
+	b.mu.Unlock()
+
+	res := make([]Stats, 0, len(queues))
+	for _, q := range queues {
+		// Queues in a Broker are always created with NewQueueWithConfig.
+		s, _ := q.Stats()
+		res = append(res, s)
+	}
+	return res
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+func TestBroker(t *testing.T) {
+	t.Run("test creating, getting, listing and deleting queues", func(t *testing.T) {
+		b := NewBroker[string]()
+
+		_, err := b.CreateQueue(DefaultConfig())
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "CreateQueue() with an empty name returned incorrect error", false)
+
+		_, err = b.CreateQueue(QueueConfig{name: "x"})
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "CreateQueue() with a manually created config returned incorrect error", false)
+		testutil.AssertDeepEqual(t, b.ListQueues(), []string{}, "CreateQueue() with an invalid config created a queue", false)
+
+		configB, _ := DefaultConfig().WithName("b")
+		configA, _ := DefaultConfig().WithName("a")
+		qb, err := b.CreateQueue(configB)
+		testutil.AssertEqual(t, err, nil, "CreateQueue() returned an unexpected error", false)
+		testutil.AssertEqual(t, qb.GetConfig().Name(), "b", "created queue has incorrect name", false)
+		_, err = b.CreateQueue(configA)
+		testutil.AssertEqual(t, err, nil, "CreateQueue() returned an unexpected error", false)
+
+		_, err = b.CreateQueue(configB)
+		testutil.AssertEqual(t, err, ErrQueueExists, "CreateQueue() with a duplicate name returned incorrect error", false)
+
+		got, err := b.GetQueue("b")
+		testutil.AssertEqual(t, err, nil, "GetQueue() returned an unexpected error", false)
+		testutil.AssertEqual(t, got, qb, "GetQueue() returned incorrect queue", false)
+
+		_, err = b.GetQueue("c")
+		testutil.AssertEqual(t, err, ErrQueueNotFound, "GetQueue() with an unknown name returned incorrect error", false)
+
+		names := b.ListQueues()
+		testutil.AssertEqual(t, len(names), 2, "ListQueues() returned incorrect amount of names", false)
+		testutil.AssertDeepEqual(t, names, []string{"a", "b"}, "ListQueues() returned incorrect names", false)
+
+		err = b.DeleteQueue("b")
+		testutil.AssertEqual(t, err, nil, "DeleteQueue() returned an unexpected error", false)
+		err = b.DeleteQueue("b")
+		testutil.AssertEqual(t, err, ErrQueueNotFound, "DeleteQueue() with an unknown name returned incorrect error", false)
+		_, err = b.GetQueue("b")
+		testutil.AssertEqual(t, err, ErrQueueNotFound, "GetQueue() after DeleteQueue() returned incorrect error", false)
+
+		_, err = b.CreateQueue(configB)
+		testutil.AssertEqual(t, err, nil, "CreateQueue() after DeleteQueue() returned an unexpected error", false)
+	})
+}
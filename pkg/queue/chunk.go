@@ -0,0 +1,161 @@
+package queue
+
+import "math"
+
+// Number of messages in a chunk. Used for Queue internals.
+const chunkSize = 256
+
+// Maximum number of empty chunks a Queue keeps for reuse.
+const maxFreeChunks = 16
+
+// Fixed-size array of messages, linked into a list of chunks.
+// Used for Queue internals.
+//
+// The messages of a Queue are stored in a list of chunks from the head
+// chunk to the tail chunk. Chunks emptied by reads and cleanup are kept
+// in a free list and reused for new messages, so adding and removing
+// messages allocates only when the Queue grows.
+type chunk[T any] struct {
+	messages [chunkSize]Message[T]
+	sizes    [chunkSize]uint64
+	next     *chunk[T]
+}
+
+// Position of a message in the list of chunks. Used for Queue internals.
+type position[T any] struct {
+	chunk *chunk[T]
+	index int
+}
+
+// Moves the position to the next message.
+func (p *position[T]) advance() {
+	p.index++
+	if p.index == chunkSize {
+		p.chunk = p.chunk.next
+		p.index = 0
+	}
+}
+
+// Internal method to get the position of the message n messages
+// after the head of the Queue.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
+func (q *Queue[T]) positionNoLock(n uint64) position[T] {
+	c := q.head
+	index := uint64(q.headIndex) + n
+	for index >= chunkSize {
+		c = c.next
+		index -= chunkSize
+	}
+	return position[T]{chunk: c, index: int(index)}
+}
+
+// Internal method to write a message to the tail of the Queue.
+// The message is not visible to consumers until tailOffset is advanced.
+// Does not lock the Queue; assumes that tailMu is already
+// held when this function is called.
+func (q *Queue[T]) pushNoLock(msg Message[T], size uint64) {
+	q.tail.messages[q.tailIndex] = msg
+	q.tail.sizes[q.tailIndex] = size
+	q.tailIndex++
+	if q.tailIndex == chunkSize {
+		// The next chunk is linked before the message becomes visible,
+		// so consumers can always move on from a fully read chunk.
+		q.tail.next = q.newChunk()
+		q.tail = q.tail.next
+		q.tailIndex = 0
+	}
+}
+
+// Internal method to get the message at the head of the Queue.
+// The Queue must not be empty.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
+func (q *Queue[T]) peekNoLock() *Message[T] {
+	return &q.head.messages[q.headIndex]
+}
+
+// Internal method to remove the message at the head of the Queue.
+// The Queue must not be empty.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
+func (q *Queue[T]) popNoLock() {
+	// Zero the message so that the Queue does not keep its value alive.
+	q.head.messages[q.headIndex] = Message[T]{}
+	q.bytes.Add(-q.head.sizes[q.headIndex])
+	q.headOffset++
+	q.headIndex++
+	if q.headIndex == chunkSize {
+		emptied := q.head
+		q.head = q.head.next
+		q.headIndex = 0
+		q.freeChunk(emptied)
+	}
+}
+
+// Internal method to get an empty chunk, reusing a freed one if possible.
+func (q *Queue[T]) newChunk() *chunk[T] {
+	q.freeMu.Lock()
+	defer q.freeMu.Unlock()
+
+	if q.free == nil {
+		return new(chunk[T])
+	}
+	c := q.free
+	q.free = c.next
+	q.freeCount--
+	c.next = nil
+	return c
+}
+
+// Internal method to keep an emptied chunk for reuse.
+func (q *Queue[T]) freeChunk(c *chunk[T]) {
+	q.freeMu.Lock()
+	defer q.freeMu.Unlock()
+
+	if q.freeCount >= maxFreeChunks {
+		return
+	}
+	c.next = q.free
+	q.free = c
+	q.freeCount++
+}
+
+// Internal method to get the total estimated size of the messages in the Queue.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
+func (q *Queue[T]) bytesNoLock() uint64 {
+	// A batch is counted in bytes only after it becomes visible, so a
+	// consumer can briefly make bytes negative by removing messages of
+	// a batch that is not counted yet.
+	return uint64(max(int64(q.bytes.Load()), 0))
+}
+
+// Internal method to check whether the sizes of messages are needed,
+// i.e. whether retentionBytes is limited.
+// Does not lock the Queue; assumes that either tailMu or headMu is
+// already held when this function is called.
+func (q *Queue[T]) sizesNeededNoLock() bool {
+	return q.config.retentionBytes != math.MaxUint64
+}
+
+// Internal method to estimate the sizes of all messages in the Queue again
+// after sizes became needed or stop being needed. If they are not needed,
+// all sizes are set to 0.
+// Does not lock the Queue; assumes that both tailMu and headMu are
+// already held when this function is called.
+func (q *Queue[T]) resizeNoLock() {
+	estimate := q.sizesNeededNoLock()
+	total := uint64(0)
+	pos := q.positionNoLock(0)
+	for i := uint64(0); i < q.lengthNoLock(); i++ {
+		var size uint64
+		if estimate {
+			size = q.config.sizeEstimator(pos.chunk.messages[pos.index].Val)
+		}
+		pos.chunk.sizes[pos.index] = size
+		total += size
+		pos.advance()
+	}
+	q.bytes.Store(total)
+}
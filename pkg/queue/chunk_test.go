@@ -0,0 +1,94 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+func TestChunks(t *testing.T) {
+	t.Run("test emptied chunks are reused", func(t *testing.T) {
+		q := NewQueue[int]()
+		first := q.head
+
+		vals := make([]int, chunkSize)
+		_ = q.AddMany(vals)
+		_, _ = q.ReadMany(chunkSize)
+		testutil.AssertEqual(t, q.freeCount, 1, "emptied chunk was not freed", false)
+
+		_ = q.AddMany(vals)
+		testutil.AssertEqual(t, q.tail, first, "freed chunk was not reused", false)
+		testutil.AssertEqual(t, q.freeCount, 0, "reused chunk was not removed from the free list", false)
+
+		for i := 0; i < maxFreeChunks*2; i++ {
+			_ = q.AddMany(vals)
+		}
+		_, _ = q.ReadMany(chunkSize * (maxFreeChunks*2 + 1))
+		testutil.AssertEqual(t, q.freeCount, maxFreeChunks, "incorrect amount of freed chunks kept", false)
+	})
+
+	t.Run("test messages across chunk boundaries", func(t *testing.T) {
+		q := NewQueue[int]()
+		n := chunkSize*3 + 7
+		for i := 0; i < n; i++ {
+			_ = q.Add(i)
+		}
+
+		tx := BeginTx()
+		msgs, _ := TxReadMany(tx, q, chunkSize+3)
+		msg, _ := TxRead(tx, q)
+		testutil.AssertEqual(t, msgs[chunkSize].Val, chunkSize, "TxReadMany() across a chunk boundary returned incorrect value", false)
+		testutil.AssertEqual(t, msg.Val, chunkSize+3, "TxRead() after a chunk boundary returned incorrect value", false)
+		_ = tx.Commit()
+
+		for i := chunkSize + 4; i < n; i++ {
+			msg, err := q.Read()
+			testutil.AssertEqual(t, err, nil, "Read() returned an unexpected error", true)
+			testutil.AssertEqual(t, msg.Val, i, fmt.Sprintf("Read() number %d returned incorrect value", i), true)
+			testutil.AssertEqual(t, msg.Offset, uint64(i), fmt.Sprintf("Read() number %d returned incorrect offset", i), true)
+		}
+		testutil.AssertEqual(t, q.bytes.Load(), 0, "incorrect total size of messages in an empty queue", false)
+	})
+
+	t.Run("test AddMany and ReadMany do not allocate per message", func(t *testing.T) {
+		intQueue := NewQueue[[4]int]()
+		intVals := make([][4]int, 100)
+		stringQueue := NewQueue[string]()
+		stringVals := make([]string, 100)
+		for i := range stringVals {
+			stringVals[i] = fmt.Sprint(i)
+		}
+
+		// Only the slice returned by ReadMany is allocated once the chunks
+		// of the Queues can be reused.
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = intQueue.AddMany(intVals)
+			_, _ = intQueue.ReadMany(len(intVals))
+		})
+		testutil.AssertEqual(t, allocs, 1, "AddMany() and ReadMany() of [4]int messages allocated incorrect amount", false)
+
+		allocs = testing.AllocsPerRun(100, func() {
+			_ = stringQueue.AddMany(stringVals)
+			_, _ = stringQueue.ReadMany(len(stringVals))
+		})
+		testutil.AssertEqual(t, allocs, 1, "AddMany() and ReadMany() of string messages allocated incorrect amount", false)
+	})
+
+	t.Run("test messages being added are not counted in the total size", func(t *testing.T) {
+		config, _ := DefaultConfig().WithRetentionBytes(10)
+		q := NewQueueWithConfig[string](config)
+		_ = q.Add("aaaa")
+
+		// Write a message to the tail without making it visible, like a
+		// producer in the middle of AddMany.
+		q.tailMu.Lock()
+		q.pushNoLock(Message[string]{Val: "bbbbbbbb", Offset: 1}, 8)
+		q.tailMu.Unlock()
+
+		removed, _ := q.Cleanup()
+		testutil.AssertEqual(t, removed, 0, "Cleanup() removed visible messages to make room for a message being added", false)
+		stats, _ := q.Stats()
+		testutil.AssertEqual(t, stats.Bytes, 4, "Stats() counted a message being added in Bytes", false)
+	})
+}
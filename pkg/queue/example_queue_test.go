@@ -1,7 +1,10 @@
 package queue_test
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/VillePuuska/Message-queue/pkg/queue"
 )
@@ -17,7 +20,7 @@ func Example() {
 	// return the error ErrImproperlyInitializedQueue otherwise.
 	invalidQueue := new(queue.Queue[int])
 	_, err := invalidQueue.IsEmpty()
-	fmt.Println(err.Error()) // improperly initialized queue, tail is nil
+	fmt.Println(err.Error()) // improperly initialized queue, use NewQueue or NewQueueWithConfig
 
 	// The basic methods of Queue are: IsEmpty, Length, Add, AddMany, Read, ReadMany,
 	// PeekNext, and Cleanup.
@@ -42,7 +45,7 @@ func Example() {
 	// The Message[T] object you read from the Queue, contains the actual value in the Val attribute.
 	fmt.Println(msg.Val) // 123
 	// The Message[T] object also contains the following metadata fields:
-	//   - Offset to keep track of how many messages have been added to the Queue in total; loops to 0 after MaxUint64 messages.
+	//   - Offset to keep track of how many messages have been added to the Queue in total; wraps to 0 after MaxUint64.
 	//   - LogAppendTime to store a timestamp for the time the message was added to the Queue.
 	fmt.Println(msg.Offset) // 0
 
@@ -111,7 +114,62 @@ func Example() {
 	msgString, _ = stringQueue2.Read()
 	fmt.Println(msgString.Val) // b
 
-	// Output: improperly initialized queue, tail is nil
+	// If you need to know which Offsets your messages got, use Append or AppendMany instead
+	// of Add or AddMany. They return an AppendResult with the offset range and the
+	// LogAppendTime assigned to the messages.
+	res, _ := stringQueue2.AppendMany([]string{"d", "e"})
+	fmt.Println(res.FirstOffset, res.Count) // 3 2
+	fmt.Println(res.Offsets())              // [3 4]
+
+	// If you would rather wait for messages than get ErrQueueIsEmpty from an empty Queue,
+	// use ReadWait or ReadManyWait. They block until messages are added or the given
+	// context.Context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stringMsgs, _ := stringQueue2.ReadManyWait(ctx, 10)
+	fmt.Println(len(stringMsgs)) // 3
+
+	// To manage many Queues by name, use a Broker. CreateQueue creates a Queue named by its
+	// QueueConfig, and GetQueue, DeleteQueue, and ListQueues work with the Queues by name.
+	// Creating a second Queue with the same name returns the error ErrQueueExists.
+	broker := queue.NewBroker[string]()
+	ordersConfig, _ := queue.DefaultConfig().WithName("orders")
+	_, _ = broker.CreateQueue(ordersConfig)
+	orders, _ := broker.GetQueue("orders")
+	_ = orders.Add("order 1")
+	fmt.Println(broker.ListQueues()) // [orders]
+
+	// When you are done with a Queue, call Close. After that, adding messages returns the
+	// error ErrQueueClosed, and goroutines waiting in ReadWait or ReadManyWait on the empty
+	// Queue are woken up with the same error. Messages already in the Queue can still be
+	// read. Drain closes the Queue and waits until consumers have read all remaining
+	// messages.
+	_ = orders.Close()
+	err = orders.Add("order 2")
+	fmt.Println(err.Error()) // queue is closed
+
+	// Stats returns the statistics of a Queue. WriteMetrics writes statistics in the
+	// Prometheus text format, and a Broker can serve the statistics of all its Queues with
+	// the http.Handler returned by MetricsHandler.
+	stats, _ := orders.Stats()
+	fmt.Println(stats.Length, stats.Appended) // 1 1
+	http.Handle("/metrics", broker.MetricsHandler())
+
+	// Lag returns how far consumers are behind. To be notified when consumers fall behind,
+	// create the Queue with lag thresholds and a callback. The callback is called once when
+	// the Queue starts exceeding a threshold and once when it stops.
+	lagConfig, _ := queue.DefaultConfig().WithName("payments")
+	lagConfig, _ = lagConfig.WithLagThresholds(2, time.Minute)
+	lagConfig, _ = lagConfig.WithLagCallback(func(queueName string, lag queue.Lag, exceeded bool) {
+		fmt.Printf("queue %s exceeds lag thresholds: %t, depth %d\n", queueName, exceeded, lag.Depth)
+	})
+	payments, _ := broker.CreateQueue(lagConfig)
+	_ = payments.AddMany([]string{"a", "b", "c"}) // queue payments exceeds lag thresholds: true, depth 3
+	_, _ = payments.ReadMany(2)                   // queue payments exceeds lag thresholds: false, depth 1
+	lag, _ := payments.Lag()
+	fmt.Println(lag.HeadOffset, lag.TailOffset, lag.Depth) // 2 3 1
+
+	// Output: improperly initialized queue, use NewQueue or NewQueueWithConfig
 	// true
 	// 0
 	// false
@@ -128,4 +186,28 @@ func Example() {
 	// 3
 	// 2
 	// b
+	// 3 2
+	// [3 4]
+	// 3
+	// [orders]
+	// queue is closed
+	// 1 1
+	// queue payments exceeds lag thresholds: true, depth 3
+	// queue payments exceeds lag thresholds: false, depth 1
+	// 2 3 1
+}
+
+// Example demonstrating how to get the offsets assigned to added messages.
+func ExampleQueue_AppendMany() {
+	q := queue.NewQueue[string]()
+	_ = q.Add("a")
+
+	// AppendMany works like AddMany, but also returns the offsets and the
+	// append time assigned to the messages.
+	res, _ := q.AppendMany([]string{"b", "c"})
+	fmt.Println(res.FirstOffset, res.Count) // 1 2
+	fmt.Println(res.Offsets())              // [1 2]
+
+	// Output: 1 2
+	// [1 2]
 }
@@ -0,0 +1,68 @@
+package queue
+
+import "time"
+
+// Internal method to start background cleanup if the cleanupInterval
+// of the Queue is positive and the Queue is not closed.
+// Does not lock the Queue; assumes that both tailMu and headMu are
+// already held when this function is called.
+func (q *Queue[T]) startJanitorNoLock() {
+	if q.config.cleanupInterval <= 0 || q.closed {
+		return
+	}
+	q.janitorStop = make(chan struct{})
+	q.janitorMu.Lock()
+	q.janitorsRunning++
+	q.janitorMu.Unlock()
+	// The ticker is created here rather than in the goroutine so that
+	// time passing on the Clock right after this call is not missed.
+	ticks, stopTicker := q.config.clock.NewTicker(q.config.cleanupInterval)
+	go q.runJanitor(ticks, stopTicker, q.janitorStop)
+}
+
+// Internal method to run cleanup and check the lag on every tick until
+// stop is closed. Stops the ticker when returning.
+func (q *Queue[T]) runJanitor(ticks <-chan time.Time, stopTicker func(), stop chan struct{}) {
+	defer q.updateJanitors(-1, 0)
+	defer stopTicker()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticks:
+		}
+
+		q.headMu.Lock()
+		// stop might have been closed while waiting for headMu.
+		if q.janitorStop != stop {
+			q.headMu.Unlock()
+			return
+		}
+		removed := q.cleanup()
+		name, callback := q.config.name, q.config.cleanupCallback
+		check := q.checkLagNoLock()
+		q.headMu.Unlock()
+
+		// The callbacks might call Close, which must not wait for this goroutine.
+		closed := q.updateJanitors(0, 1)
+		if removed > 0 && callback != nil && !closed {
+			callback(name, removed)
+		}
+		q.notifyLag(check)
+		q.updateJanitors(0, -1)
+	}
+}
+
+// Internal method to change the counts of running background cleanup
+// goroutines and of those calling callbacks. Returns whether Close has
+// been called.
+func (q *Queue[T]) updateJanitors(running, calling int) bool {
+	q.janitorMu.Lock()
+	defer q.janitorMu.Unlock()
+
+	q.janitorsRunning += running
+	q.janitorsCalling += calling
+	q.janitorCond.Broadcast()
+	return q.janitorsClosed
+}
@@ -0,0 +1,208 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+func TestJanitor(t *testing.T) {
+	t.Run("test calling Close() on a manually initialized Queue returns correct error", func(t *testing.T) {
+		q := Queue[string]{}
+
+		err := q.Close()
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "Close() on a manually created queue returned incorrect error", false)
+	})
+
+	t.Run("test background cleanup removes expired messages from an idle queue", func(t *testing.T) {
+		removedCh := make(chan uint64, 10)
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithName("asd")
+		config, _ = config.WithClock(clock)
+		config, _ = config.WithRetentionTime(time.Millisecond)
+		config, _ = config.WithCleanupInterval(time.Millisecond * 5)
+		config, _ = config.WithCleanupCallback(func(queueName string, removed uint64) {
+			testutil.AssertEqual(t, queueName, "asd", "CleanupCallback was called with incorrect queue name", false)
+			removedCh <- removed
+		})
+		q := NewQueueWithConfig[string](config)
+		defer q.Close()
+
+		_ = q.AddMany([]string{"asd", "dsa"})
+		clock.Advance(time.Millisecond * 5)
+
+		select {
+		case removed := <-removedCh:
+			testutil.AssertEqual(t, removed, 2, "CleanupCallback was called with incorrect removed count", false)
+		case <-time.After(time.Second * 5):
+			t.Fatal("background cleanup did not remove expired messages")
+		}
+
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 0, "background cleanup did not remove expired messages, incorrect length", false)
+
+		err := q.Close()
+		testutil.AssertEqual(t, err, nil, "Close() returned an unexpected error", false)
+		err = q.Close()
+		testutil.AssertEqual(t, err, nil, "second Close() returned an unexpected error", false)
+		waitForJanitors(t, q)
+
+		_ = q.Add("asd")
+		clock.Advance(time.Millisecond * 10)
+		select {
+		case <-removedCh:
+			t.Error("CleanupCallback was called after Close()")
+		default:
+		}
+	})
+
+	t.Run("test UpdateConfig starts and stops background cleanup", func(t *testing.T) {
+		removedCh := make(chan uint64, 10)
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithClock(clock)
+		config, _ = config.WithCleanupCallback(func(queueName string, removed uint64) {
+			removedCh <- removed
+		})
+		q := NewQueueWithConfig[string](config)
+		defer q.Close()
+
+		config, _ = q.GetConfig().WithRetentionTime(time.Millisecond)
+		_ = q.UpdateConfig(config)
+		_ = q.Add("asd")
+		clock.Advance(time.Millisecond * 5)
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 1, "cleanup ran without background cleanup", false)
+
+		config, _ = config.WithCleanupInterval(time.Millisecond)
+		_ = q.UpdateConfig(config)
+		clock.Advance(time.Millisecond)
+		select {
+		case removed := <-removedCh:
+			testutil.AssertEqual(t, removed, 1, "CleanupCallback was called with incorrect removed count", false)
+		case <-time.After(time.Second * 5):
+			t.Fatal("background cleanup did not start after UpdateConfig()")
+		}
+		got, _ = q.Length()
+		testutil.AssertEqual(t, got, 0, "background cleanup did not remove expired messages, incorrect length", false)
+
+		config, _ = config.WithCleanupInterval(0)
+		_ = q.UpdateConfig(config)
+		waitForJanitors(t, q)
+		_ = q.Add("asd")
+		clock.Advance(time.Millisecond * 5)
+		got, _ = q.Length()
+		testutil.AssertEqual(t, got, 1, "cleanup ran after UpdateConfig() stopped background cleanup", false)
+	})
+
+	t.Run("test background cleanup goroutines exit after Close() when background cleanup was restarted", func(t *testing.T) {
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithClock(clock)
+		config, _ = config.WithRetentionCount(1)
+		config, _ = config.WithCleanupInterval(time.Millisecond)
+		config, _ = config.WithCleanupCallback(func(queueName string, removed uint64) {})
+		q := NewQueueWithConfig[string](config)
+
+		for i := 0; i < 100; i++ {
+			_ = q.AddMany([]string{"asd", "dsa"})
+			config, _ = config.WithCleanupInterval(time.Millisecond * time.Duration(i%2+1))
+			_ = q.UpdateConfig(config)
+			clock.Advance(time.Millisecond * 2)
+		}
+		_ = q.Close()
+		waitForJanitors(t, q)
+	})
+
+	t.Run("test calling Close() from CleanupCallback does not deadlock", func(t *testing.T) {
+		closeErrCh := make(chan error, 1)
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithClock(clock)
+		config, _ = config.WithRetentionCount(1)
+		config, _ = config.WithCleanupInterval(time.Millisecond)
+		var q *Queue[string]
+		config, _ = config.WithCleanupCallback(func(queueName string, removed uint64) {
+			closeErrCh <- q.Close()
+		})
+		q = NewQueueWithConfig[string](config)
+		_ = q.AddMany([]string{"asd", "dsa"})
+		clock.Advance(time.Millisecond)
+
+		select {
+		case err := <-closeErrCh:
+			testutil.AssertEqual(t, err, nil, "Close() from CleanupCallback returned an unexpected error", false)
+		case <-time.After(time.Second * 5):
+			t.Fatal("Close() from CleanupCallback did not return")
+		}
+		waitForJanitors(t, q)
+	})
+
+	t.Run("test calling DeleteQueue() from CleanupCallback does not deadlock", func(t *testing.T) {
+		deleteErrCh := make(chan error, 1)
+		b := NewBroker[string]()
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithName("asd")
+		config, _ = config.WithClock(clock)
+		config, _ = config.WithRetentionCount(1)
+		config, _ = config.WithCleanupInterval(time.Millisecond)
+		config, _ = config.WithCleanupCallback(func(queueName string, removed uint64) {
+			deleteErrCh <- b.DeleteQueue(queueName)
+		})
+		q, _ := b.CreateQueue(config)
+		_ = q.AddMany([]string{"asd", "dsa"})
+		clock.Advance(time.Millisecond)
+
+		select {
+		case err := <-deleteErrCh:
+			testutil.AssertEqual(t, err, nil, "DeleteQueue() from CleanupCallback returned an unexpected error", false)
+		case <-time.After(time.Second * 5):
+			t.Fatal("DeleteQueue() from CleanupCallback did not return")
+		}
+		waitForJanitors(t, q)
+		_, err := b.GetQueue("asd")
+		testutil.AssertEqual(t, err, ErrQueueNotFound, "GetQueue() after DeleteQueue() returned incorrect error", false)
+	})
+
+	t.Run("test calling Close() from LagCallback delivered by background cleanup does not deadlock", func(t *testing.T) {
+		closeErrCh := make(chan error, 1)
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithClock(clock)
+		config, _ = config.WithLagThresholds(0, time.Millisecond)
+		config, _ = config.WithCleanupInterval(time.Millisecond)
+		var q *Queue[string]
+		config, _ = config.WithLagCallback(func(queueName string, lag Lag, exceeded bool) {
+			if exceeded {
+				closeErrCh <- q.Close()
+			}
+		})
+		q = NewQueueWithConfig[string](config)
+		_ = q.Add("asd")
+		clock.Advance(time.Millisecond * 2)
+
+		select {
+		case err := <-closeErrCh:
+			testutil.AssertEqual(t, err, nil, "Close() from LagCallback returned an unexpected error", false)
+		case <-time.After(time.Second * 5):
+			t.Fatal("Close() from LagCallback did not return")
+		}
+		waitForJanitors(t, q)
+	})
+}
+
+// Waits until all background cleanup goroutines of q have returned.
+func waitForJanitors[T any](t *testing.T, q *Queue[T]) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		q.janitorMu.Lock()
+		for q.janitorsRunning > 0 {
+			q.janitorCond.Wait()
+		}
+		q.janitorMu.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("background cleanup goroutines did not return")
+	}
+}
@@ -0,0 +1,145 @@
+package queue
+
+import "time"
+
+// Lag type contains how far the consumers of a Queue are behind
+// its producers at a point in time.
+type Lag struct {
+	// Offset of the next message to read and the offset the next
+	// added message gets.
+	HeadOffset uint64
+	TailOffset uint64
+
+	// Count of unread messages, i.e. TailOffset - HeadOffset.
+	Depth uint64
+
+	// Time since the LogAppendTime of the oldest unread message;
+	// 0 if the Queue is empty.
+	OldestAge time.Duration
+}
+
+// Result of a lag check. Used for Queue internals to call the
+// LagCallback after the Queue is unlocked. If the check changed
+// whether the Queue exceeds its lag thresholds, change is the
+// number of the change, counting from 1.
+type lagCheck struct {
+	callback LagCallback
+	name     string
+	lag      Lag
+	exceeded bool
+	change   uint64
+}
+
+// Internal method to call the LagCallback if the lag check found that
+// the Queue started or stopped exceeding its lag thresholds.
+//
+// The LagCallback is called for the changes in the order they happened,
+// even though lag checks in different goroutines can reach this method
+// in a different order. A change is delivered by the goroutine that finds
+// it next in line, either its own goroutine or one delivering earlier
+// changes. LagCallbacks can use the Queue, since no lock is held while
+// calling them.
+// Assumes that the Queue is not locked when this function is called.
+func (q *Queue[T]) notifyLag(check lagCheck) {
+	if check.change == 0 {
+		return
+	}
+
+	q.lagMu.Lock()
+	if q.lagPending == nil {
+		q.lagPending = make(map[uint64]lagCheck)
+	}
+	q.lagPending[check.change] = check
+	if q.lagDelivering {
+		q.lagMu.Unlock()
+		return
+	}
+	q.lagDelivering = true
+	for {
+		next, ok := q.lagPending[q.lagDelivered+1]
+		if !ok {
+			break
+		}
+		delete(q.lagPending, q.lagDelivered+1)
+		q.lagDelivered++
+		q.lagMu.Unlock()
+
+		if next.callback != nil {
+			next.callback(next.name, next.lag, next.exceeded)
+		}
+
+		q.lagMu.Lock()
+	}
+	q.lagDelivering = false
+	q.lagMu.Unlock()
+}
+
+// Method to get the current lag of the Queue.
+func (q *Queue[T]) Lag() (Lag, error) {
+	q.headMu.Lock()
+	defer q.headMu.Unlock()
+
+	if !q.isProperlyInitialized() {
+		return Lag{}, ErrImproperlyInitializedQueue
+	}
+
+	if q.config.autoCleanup {
+		q.cleanup()
+	}
+
+	return q.lagNoLock(), nil
+}
+
+// Internal method to get the current lag of the Queue.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
+func (q *Queue[T]) lagNoLock() Lag {
+	res := Lag{
+		HeadOffset: q.headOffset,
+		TailOffset: q.tailOffset.Load(),
+	}
+	res.Depth = res.TailOffset - res.HeadOffset
+	if res.Depth > 0 {
+		res.OldestAge = max(q.config.clock.Now().Sub(q.peekNoLock().LogAppendTime), 0)
+	}
+	return res
+}
+
+// Method to check the lag of the Queue against its lag thresholds.
+// Returns true if the Queue exceeds its lag thresholds. Calls the
+// LagCallback of the Queue if the Queue started or stopped exceeding
+// its lag thresholds since the last lag check.
+func (q *Queue[T]) CheckLag() (bool, error) {
+	if !q.isProperlyInitialized() {
+		return false, ErrImproperlyInitializedQueue
+	}
+
+	q.headMu.Lock()
+	check := q.checkLagNoLock()
+	q.headMu.Unlock()
+
+	q.notifyLag(check)
+	return check.exceeded, nil
+}
+
+// Internal method to check the lag of the Queue against its lag thresholds
+// and to remember the result for the next lag check.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
+func (q *Queue[T]) checkLagNoLock() lagCheck {
+	lag := q.lagNoLock()
+	exceeded := (q.config.lagDepth > 0 && lag.Depth > q.config.lagDepth) ||
+		(q.config.lagAge > 0 && lag.OldestAge > q.config.lagAge)
+	check := lagCheck{
+		callback: q.config.lagCallback,
+		name:     q.config.name,
+		lag:      lag,
+		exceeded: exceeded,
+	}
+	if exceeded != q.lagExceeded {
+		q.lagExceeded = exceeded
+		q.lagChanges++
+		check.change = q.lagChanges
+	}
+	return check
+}
@@ -0,0 +1,166 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+type lagEvent struct {
+	name     string
+	lag      Lag
+	exceeded bool
+}
+
+func TestLag(t *testing.T) {
+	t.Run("test calling lag methods on a manually initialized Queue return correct errors", func(t *testing.T) {
+		q := Queue[string]{}
+
+		_, err := q.Lag()
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "Lag() on a manually created queue returned incorrect error", false)
+		_, err = q.CheckLag()
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "CheckLag() on a manually created queue returned incorrect error", false)
+	})
+
+	t.Run("test lag thresholds config validation", func(t *testing.T) {
+		_, err := DefaultConfig().WithLagThresholds(10, -time.Second)
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "WithLagThresholds() with a negative age returned incorrect error", false)
+		_, err = DefaultConfig().WithLagThresholds(0, 0)
+		testutil.AssertEqual(t, err, nil, "WithLagThresholds() with disabled thresholds returned an unexpected error", false)
+	})
+
+	t.Run("test Lag", func(t *testing.T) {
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithClock(clock)
+		config, _ = config.WithInitialOffset(100)
+		q := NewQueueWithConfig[string](config)
+
+		lag, err := q.Lag()
+		testutil.AssertEqual(t, err, nil, "Lag() returned an unexpected error", false)
+		testutil.AssertEqual(t, lag, Lag{HeadOffset: 100, TailOffset: 100}, "Lag() of an empty queue is incorrect", false)
+
+		_ = q.AddMany([]string{"a", "b", "c"})
+		clock.Advance(time.Second)
+		_, _ = q.Read()
+
+		lag, _ = q.Lag()
+		testutil.AssertEqual(t, lag, Lag{HeadOffset: 101, TailOffset: 103, Depth: 2, OldestAge: time.Second}, "Lag() is incorrect", false)
+	})
+
+	t.Run("test depth threshold callback is edge-triggered", func(t *testing.T) {
+		var events []lagEvent
+		config, _ := DefaultConfig().WithName("asd")
+		config, _ = config.WithLagThresholds(2, 0)
+		config, _ = config.WithLagCallback(func(queueName string, lag Lag, exceeded bool) {
+			events = append(events, lagEvent{queueName, lag, exceeded})
+		})
+		q := NewQueueWithConfig[string](config)
+
+		_ = q.AddMany([]string{"a", "b"})
+		testutil.AssertEqual(t, len(events), 0, "LagCallback was called before the depth threshold was exceeded", false)
+
+		_ = q.Add("c")
+		_ = q.Add("d")
+		testutil.AssertEqual(t, len(events), 1, "LagCallback was not called exactly once when the depth threshold was exceeded", false)
+		testutil.AssertEqual(t, events[0].name, "asd", "LagCallback was called with incorrect queue name", false)
+		testutil.AssertEqual(t, events[0].exceeded, true, "LagCallback was called with incorrect exceeded", false)
+		testutil.AssertEqual(t, events[0].lag.Depth, 3, "LagCallback was called with incorrect depth", false)
+
+		_, _ = q.Read()
+		testutil.AssertEqual(t, len(events), 1, "LagCallback was called while the depth threshold was still exceeded", false)
+		_, _ = q.ReadMany(2)
+		testutil.AssertEqual(t, len(events), 2, "LagCallback was not called when reads made the lag recover", false)
+		testutil.AssertEqual(t, events[1].exceeded, false, "LagCallback was called with incorrect exceeded", false)
+
+		exceeded, err := q.CheckLag()
+		testutil.AssertEqual(t, err, nil, "CheckLag() returned an unexpected error", false)
+		testutil.AssertEqual(t, exceeded, false, "CheckLag() returned incorrect value", false)
+		testutil.AssertEqual(t, len(events), 2, "LagCallback was called again without a change", false)
+
+		tx := BeginTx()
+		_ = TxAddMany(tx, q, []string{"a", "b", "c"})
+		_ = tx.Commit()
+		testutil.AssertEqual(t, len(events), 3, "LagCallback was not called after Commit() exceeded the depth threshold", false)
+		testutil.AssertEqual(t, events[2].exceeded, true, "LagCallback was called with incorrect exceeded", false)
+	})
+
+	t.Run("test age threshold with CheckLag and background cleanup", func(t *testing.T) {
+		events := make(chan lagEvent, 10)
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithClock(clock)
+		config, _ = config.WithLagThresholds(0, time.Minute)
+		config, _ = config.WithLagCallback(func(queueName string, lag Lag, exceeded bool) {
+			events <- lagEvent{queueName, lag, exceeded}
+		})
+		q := NewQueueWithConfig[string](config)
+		defer q.Close()
+
+		_ = q.Add("a")
+		clock.Advance(time.Minute * 2)
+		exceeded, _ := q.CheckLag()
+		testutil.AssertEqual(t, exceeded, true, "CheckLag() returned incorrect value", false)
+		event := <-events
+		testutil.AssertEqual(t, event.lag.OldestAge, time.Minute*2, "LagCallback was called with incorrect age", false)
+
+		_, _ = q.Read()
+		_, _ = q.CheckLag()
+		event = <-events
+		testutil.AssertEqual(t, event.exceeded, false, "LagCallback was called with incorrect exceeded", false)
+
+		config, _ = q.GetConfig().WithCleanupInterval(time.Millisecond)
+		_ = q.UpdateConfig(config)
+		_ = q.Add("b")
+		clock.Advance(time.Minute * 2)
+
+		select {
+		case event = <-events:
+			testutil.AssertEqual(t, event.exceeded, true, "LagCallback was called with incorrect exceeded by background cleanup", false)
+		case <-time.After(time.Second * 5):
+			t.Fatal("background cleanup did not check the lag")
+		}
+	})
+
+	t.Run("test LagCallback is called in the order of the changes", func(t *testing.T) {
+		var events []bool
+		config, _ := DefaultConfig().WithLagThresholds(1, 0)
+		config, _ = config.WithLagCallback(func(queueName string, lag Lag, exceeded bool) {
+			events = append(events, exceeded)
+		})
+		q := NewQueueWithConfig[string](config)
+		_ = q.AddMany([]string{"a", "b"})
+		events = events[:0]
+
+		// Two changes reaching notifyLag in the opposite order.
+		q.headMu.Lock()
+		_, first, _ := q.readManyNoLock(2)
+		q.headMu.Unlock()
+		q.tailMu.Lock()
+		q.addManyNoLock([]string{"c", "d"}, time.Now())
+		q.tailMu.Unlock()
+		q.headMu.Lock()
+		second := q.checkLagNoLock()
+		q.headMu.Unlock()
+
+		q.notifyLag(second)
+		testutil.AssertEqual(t, len(events), 0, "LagCallback was called for a change before an earlier change", false)
+		q.notifyLag(first)
+		testutil.AssertDeepEqual(t, events, []bool{false, true}, "LagCallback was called in incorrect order", false)
+	})
+
+	t.Run("test LagCallback can use the Queue", func(t *testing.T) {
+		var q *Queue[string]
+		var events []bool
+		config, _ := DefaultConfig().WithLagThresholds(1, 0)
+		config, _ = config.WithLagCallback(func(queueName string, lag Lag, exceeded bool) {
+			events = append(events, exceeded)
+			if exceeded {
+				_, _ = q.ReadMany(10)
+			}
+		})
+		q = NewQueueWithConfig[string](config)
+
+		_ = q.AddMany([]string{"a", "b"})
+		testutil.AssertDeepEqual(t, events, []bool{true, false}, "LagCallback reading the Queue was called incorrectly", false)
+	})
+}
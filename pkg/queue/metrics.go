@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Escapes label values in the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Function to write the statistics of Queues to w in the Prometheus text
+// exposition format. Each metric has a label queue with the name of the Queue.
+func WriteMetrics(w io.Writer, stats []Stats) error {
+	var buf bytes.Buffer
+
+	writeFamily := func(name, typ, help string, value func(s Stats) string) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, s := range stats {
+			fmt.Fprintf(&buf, "%s{queue=\"%s\"} %s\n", name, labelEscaper.Replace(s.Name), value(s))
+		}
+	}
+	formatUint := func(v uint64) string {
+		return strconv.FormatUint(v, 10)
+	}
+
+	writeFamily("mq_queue_length", "gauge", "Number of messages in the queue.",
+		func(s Stats) string { return formatUint(s.Length) })
+	writeFamily("mq_queue_bytes", "gauge", "Estimated total size of the messages in the queue in bytes.",
+		func(s Stats) string { return formatUint(s.Bytes) })
+	writeFamily("mq_queue_appended_total", "counter", "Total number of messages added to the queue.",
+		func(s Stats) string { return formatUint(s.Appended) })
+	writeFamily("mq_queue_consumed_total", "counter", "Total number of messages read from the queue.",
+		func(s Stats) string { return formatUint(s.Consumed) })
+	writeFamily("mq_queue_removed_total", "counter", "Total number of messages removed from the queue by cleanup.",
+		func(s Stats) string { return formatUint(s.Removed) })
+	writeFamily("mq_queue_oldest_message_age_seconds", "gauge", "Age of the oldest message in the queue.",
+		func(s Stats) string { return formatFloat(s.OldestAge.Seconds()) })
+
+	const latency = "mq_queue_read_latency_seconds"
+	fmt.Fprintf(&buf, "# HELP %s Time from adding a message to the queue to reading it.\n# TYPE %s histogram\n", latency, latency)
+	for _, s := range stats {
+		name := labelEscaper.Replace(s.Name)
+		h := s.ReadLatency
+		for i, bound := range h.Bounds {
+			fmt.Fprintf(&buf, "%s_bucket{queue=\"%s\",le=\"%s\"} %d\n", latency, name, formatFloat(bound.Seconds()), h.Counts[i])
+		}
+		fmt.Fprintf(&buf, "%s_bucket{queue=\"%s\",le=\"+Inf\"} %d\n", latency, name, h.Count)
+		fmt.Fprintf(&buf, "%s_sum{queue=\"%s\"} %s\n", latency, name, formatFloat(h.Sum.Seconds()))
+		fmt.Fprintf(&buf, "%s_count{queue=\"%s\"} %d\n", latency, name, h.Count)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Formats a float in the shortest form that represents it exactly.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Returns an http.Handler that serves the statistics of all Queues in the
+// Broker in the Prometheus text exposition format, e.g. at /metrics.
+func (b *Broker[T]) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteMetrics(w, b.Stats())
+	})
+}
@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("test WriteMetrics output", func(t *testing.T) {
+		stats := []Stats{
+			{
+				Name:      `a"b`,
+				Length:    2,
+				Appended:  5,
+				Consumed:  2,
+				Removed:   1,
+				OldestAge: time.Millisecond * 1500,
+				ReadLatency: Histogram{
+					Bounds: []time.Duration{time.Millisecond, time.Second},
+					Counts: []uint64{1, 2},
+					Count:  3,
+					Sum:    time.Millisecond * 2500,
+				},
+			},
+		}
+
+		var sb strings.Builder
+		err := WriteMetrics(&sb, stats)
+		testutil.AssertEqual(t, err, nil, "WriteMetrics() returned an unexpected error", false)
+
+		got := sb.String()
+		for _, line := range []string{
+			"# TYPE mq_queue_length gauge",
+			`mq_queue_length{queue="a\"b"} 2`,
+			`mq_queue_appended_total{queue="a\"b"} 5`,
+			`mq_queue_consumed_total{queue="a\"b"} 2`,
+			`mq_queue_removed_total{queue="a\"b"} 1`,
+			`mq_queue_oldest_message_age_seconds{queue="a\"b"} 1.5`,
+			"# TYPE mq_queue_read_latency_seconds histogram",
+			`mq_queue_read_latency_seconds_bucket{queue="a\"b",le="0.001"} 1`,
+			`mq_queue_read_latency_seconds_bucket{queue="a\"b",le="1"} 2`,
+			`mq_queue_read_latency_seconds_bucket{queue="a\"b",le="+Inf"} 3`,
+			`mq_queue_read_latency_seconds_sum{queue="a\"b"} 2.5`,
+			`mq_queue_read_latency_seconds_count{queue="a\"b"} 3`,
+		} {
+			testutil.AssertEqual(t, strings.Contains(got, line+"\n"), true, "WriteMetrics() output is missing line "+line, false)
+		}
+	})
+
+	t.Run("test Broker MetricsHandler", func(t *testing.T) {
+		b := NewBroker[string]()
+		configA, _ := DefaultConfig().WithName("a")
+		configB, _ := DefaultConfig().WithName("b")
+		qa, _ := b.CreateQueue(configA)
+		_, _ = b.CreateQueue(configB)
+		_ = qa.AddMany([]string{"asd", "dsa"})
+
+		stats := b.Stats()
+		testutil.AssertEqual(t, len(stats), 2, "Broker Stats() returned incorrect amount of stats", false)
+		testutil.AssertEqual(t, stats[0].Name, "a", "Broker Stats() returned stats in incorrect order", false)
+		testutil.AssertEqual(t, stats[0].Length, 2, "Broker Stats() returned incorrect Length", false)
+
+		rec := httptest.NewRecorder()
+		b.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		testutil.AssertEqual(t, rec.Code, 200, "MetricsHandler returned incorrect status code", false)
+		testutil.AssertEqual(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"), true, "MetricsHandler returned incorrect Content-Type", false)
+
+		body := rec.Body.String()
+		testutil.AssertEqual(t, strings.Contains(body, `mq_queue_length{queue="a"} 2`+"\n"), true, "MetricsHandler output has incorrect length for queue a", false)
+		testutil.AssertEqual(t, strings.Contains(body, `mq_queue_length{queue="b"} 0`+"\n"), true, "MetricsHandler output has incorrect length for queue b", false)
+	})
+}
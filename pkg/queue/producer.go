@@ -0,0 +1,231 @@
+package queue
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Producer[T] is a handle for adding messages to a Queue[T] exactly once.
+// Every batch added with a Producer gets the next sequence number of the
+// producer, and the Queue remembers the last sequence number and offsets
+// of each producer ID. If a batch is added again with the same sequence
+// number, e.g. when retrying after an unknown outcome, it is not added
+// a second time and the originally assigned offsets are returned instead.
+//
+// The Queue remembers a producer ID until no batch has been added with it
+// for the producerTTL of the Queue, or until RemoveProducer is called.
+// By default, producer IDs are remembered until they are removed.
+//
+// Producer methods are safe to use concurrently in multiple goroutines.
+//
+// NOTE: never create a Producer directly; use the method NewProducer
+// of a Queue instead.
+type Producer[T any] struct {
+	queue   *Queue[T]
+	id      string
+	nextSeq uint64
+	mu      sync.Mutex
+}
+
+// State of an idempotent producer. Used for Queue internals.
+type producerState struct {
+	lastSeq     uint64
+	firstOffset uint64
+	count       uint64
+	lastUsed    time.Time
+}
+
+// Function to create a Producer for the Queue with the given producer ID.
+// If the Queue already knows the producer ID, the Producer continues
+// from the sequence number after the last batch added with that ID.
+func (q *Queue[T]) NewProducer(id string) *Producer[T] {
+	q.tailMu.Lock()
+	defer q.tailMu.Unlock()
+
+	p := Producer[T]{
+		queue: q,
+		id:    id,
+	}
+	if state, ok := q.producers[id]; ok {
+		p.nextSeq = state.lastSeq + 1
+	}
+	return &p
+}
+
+// Returns the producer ID of the Producer.
+func (p *Producer[T]) ID() string {
+	return p.id
+}
+
+// Returns the sequence number the next batch added with the Producer gets.
+func (p *Producer[T]) NextSequence() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.nextSeq
+}
+
+// Method to add a single message to the Queue exactly once.
+// Returns the offset assigned to the message.
+func (p *Producer[T]) Add(val T) (uint64, error) {
+	offsets, err := p.AddMany([]T{val})
+	if err != nil {
+		return 0, err
+	}
+	if len(offsets) != 1 {
+		return 0, ErrMismatchedRetry
+	}
+	return offsets[0], nil
+}
+
+// Method to add multiple messages to the Queue exactly once with the next
+// sequence number of the Producer. Returns the offsets assigned to the messages.
+//
+// The sequence number is only advanced when the batch is added successfully,
+// so calling AddMany again after an error retries the same batch.
+func (p *Producer[T]) AddMany(vals []T) ([]uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	offsets, err := p.queue.AddManyIdempotent(p.id, p.nextSeq, vals)
+	if err != nil {
+		return nil, err
+	}
+	p.nextSeq++
+	return offsets, nil
+}
+
+// Method to add multiple messages to the Queue as the batch with sequence
+// number seq of the producer with ID producerID. Returns the offsets assigned
+// to the messages.
+//
+// If seq is the sequence number of the last batch of the producer, the batch
+// is not added again and the originally assigned offsets are returned. If the
+// batch has a different number of messages than the last batch, it cannot be
+// a retry of it, e.g. because two Producers use the same producer ID, and the
+// error ErrMismatchedRetry is returned instead.
+// If the producer ID is new to the Queue, any sequence number is accepted.
+// Otherwise, if seq is not the successor of the last sequence number of the
+// producer, returns the error ErrOutOfOrderSequence.
+// If the Queue has been closed and the batch is not a retry of the last batch
+// of the producer, returns the error ErrQueueClosed.
+func (q *Queue[T]) AddManyIdempotent(producerID string, seq uint64, vals []T) ([]uint64, error) {
+	if !q.isProperlyInitialized() {
+		return nil, ErrImproperlyInitializedQueue
+	}
+
+	offsets, afterAdd, err := q.addManyIdempotent(producerID, seq, vals)
+	if afterAdd {
+		q.afterAdd()
+	}
+	return offsets, err
+}
+
+// Internal method to add a batch of an idempotent producer.
+// Returns the offsets of the batch and whether automatic cleanup and
+// the lag check should run because the batch was added now.
+// Locks tailMu.
+func (q *Queue[T]) addManyIdempotent(producerID string, seq uint64, vals []T) ([]uint64, bool, error) {
+	q.tailMu.Lock()
+	defer q.tailMu.Unlock()
+
+	appendTime := q.config.clock.Now()
+	q.pruneProducers(appendTime)
+	state, ok := q.producers[producerID]
+	// A retry of a batch that was already added gets its offsets even
+	// if the Queue has been closed since.
+	if ok && seq == state.lastSeq {
+		if uint64(len(vals)) != state.count {
+			return nil, false, ErrMismatchedRetry
+		}
+		return offsetRange(state.firstOffset, state.count), false, nil
+	}
+	if q.closed {
+		return nil, false, ErrQueueClosed
+	}
+	if ok && seq != state.lastSeq+1 {
+		return nil, false, ErrOutOfOrderSequence
+	}
+
+	res := q.addManyNoLock(vals, appendTime)
+	state = producerState{
+		lastSeq:     seq,
+		firstOffset: res.FirstOffset,
+		count:       res.Count,
+		lastUsed:    appendTime,
+	}
+	q.producers[producerID] = state
+	if q.config.producerTTL > 0 {
+		q.producerOrder = append(q.producerOrder, dedupEntry{id: producerID, accepted: appendTime})
+	}
+
+	return res.Offsets(), q.afterAddNeededNoLock(), nil
+}
+
+// Internal method to forget producer IDs that no batch has been added
+// with for longer than producerTTL.
+// Does not lock the Queue; assumes that tailMu is already
+// held when this function is called.
+func (q *Queue[T]) pruneProducers(currTime time.Time) {
+	if q.producers == nil {
+		q.producers = make(map[string]producerState)
+	}
+	if q.config.producerTTL <= 0 {
+		return
+	}
+	expired := 0
+	for _, entry := range q.producerOrder {
+		if currTime.Sub(entry.accepted) < q.config.producerTTL {
+			break
+		}
+		// A later batch of the producer has its own entry.
+		if state, ok := q.producers[entry.id]; ok && state.lastUsed.Equal(entry.accepted) {
+			delete(q.producers, entry.id)
+		}
+		expired++
+	}
+	q.producerOrder = q.producerOrder[expired:]
+}
+
+// Internal method to rebuild the order in which producer IDs expire after
+// producerTTL was enabled or disabled. The order is only kept while
+// producerTTL is positive.
+// Does not lock the Queue; assumes that tailMu is already
+// held when this function is called.
+func (q *Queue[T]) reorderProducersNoLock() {
+	q.producerOrder = nil
+	if q.config.producerTTL <= 0 {
+		return
+	}
+	for id, state := range q.producers {
+		q.producerOrder = append(q.producerOrder, dedupEntry{id: id, accepted: state.lastUsed})
+	}
+	slices.SortFunc(q.producerOrder, func(a, b dedupEntry) int {
+		return a.accepted.Compare(b.accepted)
+	})
+}
+
+// Method to forget the state of the producer with ID producerID.
+// After this, the producer ID is new to the Queue, so a retry of its
+// last batch would be added again. Use when a producer is done.
+func (q *Queue[T]) RemoveProducer(producerID string) error {
+	if !q.isProperlyInitialized() {
+		return ErrImproperlyInitializedQueue
+	}
+
+	q.tailMu.Lock()
+	defer q.tailMu.Unlock()
+
+	delete(q.producers, producerID)
+	return nil
+}
+
+// Returns the count consecutive offsets starting from first.
+func offsetRange(first, count uint64) []uint64 {
+	res := make([]uint64, count)
+	for i := uint64(0); i < count; i++ {
+		res[i] = first + i
+	}
+	return res
+}
@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+func TestProducer(t *testing.T) {
+	t.Run("test calling AddManyIdempotent() on a manually initialized Queue returns correct error", func(t *testing.T) {
+		q := Queue[string]{}
+
+		_, err := q.AddManyIdempotent("producer", 0, []string{"asd"})
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "AddManyIdempotent() on a manually created queue returned incorrect error", false)
+	})
+
+	t.Run("test retried batches are added exactly once", func(t *testing.T) {
+		q := NewQueue[string]()
+		_ = q.Add("first")
+
+		offsets, err := q.AddManyIdempotent("producer", 5, []string{"asd", "dsa"})
+		testutil.AssertEqual(t, err, nil, "AddManyIdempotent() returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, offsets, []uint64{1, 2}, "AddManyIdempotent() returned incorrect offsets", false)
+
+		offsets, err = q.AddManyIdempotent("producer", 5, []string{"asd", "dsa"})
+		testutil.AssertEqual(t, err, nil, "retried AddManyIdempotent() returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, offsets, []uint64{1, 2}, "retried AddManyIdempotent() returned incorrect offsets", false)
+
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 3, "retried batch was added again, incorrect Length()", false)
+
+		_, err = q.AddManyIdempotent("producer", 7, []string{"asd"})
+		testutil.AssertEqual(t, err, ErrOutOfOrderSequence, "AddManyIdempotent() with a skipped sequence number returned incorrect error", false)
+		_, err = q.AddManyIdempotent("producer", 4, []string{"asd"})
+		testutil.AssertEqual(t, err, ErrOutOfOrderSequence, "AddManyIdempotent() with an old sequence number returned incorrect error", false)
+
+		offsets, err = q.AddManyIdempotent("other producer", 5, []string{"aaa"})
+		testutil.AssertEqual(t, err, nil, "AddManyIdempotent() with another producer ID returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, offsets, []uint64{3}, "AddManyIdempotent() with another producer ID returned incorrect offsets", false)
+	})
+
+	t.Run("test Producer sequence numbers", func(t *testing.T) {
+		q := NewQueue[int]()
+		p := q.NewProducer("producer")
+		testutil.AssertEqual(t, p.ID(), "producer", "Producer has incorrect ID", false)
+		testutil.AssertEqual(t, p.NextSequence(), 0, "new Producer has incorrect NextSequence()", false)
+
+		offsets, err := p.AddMany([]int{1, 2, 3})
+		testutil.AssertEqual(t, err, nil, "Producer.AddMany() returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, offsets, []uint64{0, 1, 2}, "Producer.AddMany() returned incorrect offsets", false)
+
+		offset, err := p.Add(4)
+		testutil.AssertEqual(t, err, nil, "Producer.Add() returned an unexpected error", false)
+		testutil.AssertEqual(t, offset, 3, "Producer.Add() returned incorrect offset", false)
+		testutil.AssertEqual(t, p.NextSequence(), 2, "Producer has incorrect NextSequence() after two batches", false)
+
+		// A retry of the last batch, e.g. from another Producer handle after a crash,
+		// returns the original offset.
+		offsets, err = q.AddManyIdempotent("producer", 1, []int{4})
+		testutil.AssertEqual(t, err, nil, "retried AddManyIdempotent() returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, offsets, []uint64{3}, "retried AddManyIdempotent() returned incorrect offsets", false)
+
+		resumed := q.NewProducer("producer")
+		testutil.AssertEqual(t, resumed.NextSequence(), 2, "Producer for a known producer ID has incorrect NextSequence()", false)
+
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 4, "incorrect Length() after idempotent adds", false)
+
+		_ = q.Close()
+		offsets, err = q.AddManyIdempotent("producer", 1, []int{4})
+		testutil.AssertEqual(t, err, nil, "retried AddManyIdempotent() on a closed queue returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, offsets, []uint64{3}, "retried AddManyIdempotent() on a closed queue returned incorrect offsets", false)
+		_, err = resumed.Add(5)
+		testutil.AssertEqual(t, err, ErrQueueClosed, "Producer.Add() of a new batch on a closed queue returned incorrect error", false)
+	})
+
+	t.Run("test retry with a different batch returns correct error", func(t *testing.T) {
+		q := NewQueue[int]()
+		p1 := q.NewProducer("producer")
+		p2 := q.NewProducer("producer")
+
+		offsets, err := p1.AddMany(nil)
+		testutil.AssertEqual(t, err, nil, "Producer.AddMany() with no messages returned an unexpected error", false)
+		testutil.AssertEqual(t, len(offsets), 0, "Producer.AddMany() with no messages returned incorrect amount of offsets", false)
+
+		_, err = p2.Add(1)
+		testutil.AssertEqual(t, err, ErrMismatchedRetry, "Producer.Add() with the sequence number of an empty batch returned incorrect error", false)
+		_, err = q.AddManyIdempotent("producer", 0, []int{1, 2})
+		testutil.AssertEqual(t, err, ErrMismatchedRetry, "AddManyIdempotent() with a different batch size returned incorrect error", false)
+
+		offset, err := p1.Add(3)
+		testutil.AssertEqual(t, err, nil, "Producer.Add() returned an unexpected error", false)
+		testutil.AssertEqual(t, offset, 0, "Producer.Add() returned incorrect offset", false)
+
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 1, "mismatched retries were added to the queue, incorrect Length()", false)
+	})
+
+	t.Run("test producer state expires after producerTTL and with RemoveProducer", func(t *testing.T) {
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithClock(clock)
+		config, _ = config.WithProducerTTL(time.Minute)
+		q := NewQueueWithConfig[string](config)
+
+		_, err := config.WithProducerTTL(-time.Minute)
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "WithProducerTTL() with a negative TTL returned incorrect error", false)
+
+		_, _ = q.AddManyIdempotent("a", 0, []string{"asd"})
+		_, _ = q.AddManyIdempotent("b", 0, []string{"asd"})
+		clock.Advance(time.Second * 30)
+		_, _ = q.AddManyIdempotent("b", 1, []string{"asd"})
+		clock.Advance(time.Second * 40)
+
+		_, err = q.AddManyIdempotent("c", 0, []string{"asd"})
+		testutil.AssertEqual(t, err, nil, "AddManyIdempotent() returned an unexpected error", false)
+		_, ok := q.producers["a"]
+		testutil.AssertEqual(t, ok, false, "expired producer ID was not forgotten", false)
+		_, ok = q.producers["b"]
+		testutil.AssertEqual(t, ok, true, "recently used producer ID was forgotten", false)
+
+		testutil.AssertEqual(t, q.NewProducer("a").NextSequence(), 0, "Producer for an expired producer ID has incorrect NextSequence()", false)
+
+		err = q.RemoveProducer("b")
+		testutil.AssertEqual(t, err, nil, "RemoveProducer() returned an unexpected error", false)
+		testutil.AssertEqual(t, q.NewProducer("b").NextSequence(), 0, "Producer for a removed producer ID has incorrect NextSequence()", false)
+
+		empty := Queue[string]{}
+		err = empty.RemoveProducer("b")
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "RemoveProducer() on a manually created queue returned incorrect error", false)
+	})
+
+	t.Run("test enabling producerTTL with UpdateConfig expires existing producers", func(t *testing.T) {
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithClock(clock)
+		q := NewQueueWithConfig[string](config)
+
+		_, _ = q.AddManyIdempotent("a", 0, []string{"asd"})
+		clock.Advance(time.Second * 30)
+		_, _ = q.AddManyIdempotent("b", 0, []string{"asd"})
+
+		config, _ = config.WithProducerTTL(time.Minute)
+		_ = q.UpdateConfig(config)
+		clock.Advance(time.Second * 40)
+
+		_, _ = q.AddManyIdempotent("c", 0, []string{"asd"})
+		_, ok := q.producers["a"]
+		testutil.AssertEqual(t, ok, false, "producer ID added before enabling producerTTL was not forgotten", false)
+		_, ok = q.producers["b"]
+		testutil.AssertEqual(t, ok, true, "recently used producer ID was forgotten", false)
+	})
+}
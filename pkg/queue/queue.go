@@ -9,28 +9,69 @@
 package queue
 
 import (
+	"context"
 	"errors"
 	"math"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
 	ErrQueueIsEmpty               = errors.New("queue is empty")
-	ErrImproperlyInitializedQueue = errors.New("improperly initialized queue, tail is nil")
+	ErrImproperlyInitializedQueue = errors.New("improperly initialized queue, use NewQueue or NewQueueWithConfig")
 	ErrUnimplementedMethod        = errors.New("unimplemented")
 	ErrInvalidLimit               = errors.New("limit must be positive")
 	ErrInvalidConfig              = errors.New("invalid configuration parameter")
+	ErrMismatchedIDs              = errors.New("number of deduplication IDs does not match number of messages")
+	ErrOutOfOrderSequence         = errors.New("producer sequence number out of order")
+	ErrMismatchedRetry            = errors.New("retried batch does not match the original batch")
+	ErrTxDone                     = errors.New("transaction has already been committed or aborted")
+	ErrTxConflict                 = errors.New("transaction conflicts with another consumer")
+	ErrRequestFailed              = errors.New("request handler failed")
+	ErrQueueExists                = errors.New("queue with the same name already exists")
+	ErrQueueNotFound              = errors.New("queue not found")
+	ErrQueueClosed                = errors.New("queue is closed")
 )
 
+// Counter for assigning a unique id to every Queue.
+var queueIDs atomic.Uint64
+
 // Message type contains the actual message stored in a Queue
 // and related metadata (offset, logAppendTime).
+//
+// Offsets wrap to 0 after MaxUint64, so they must not be compared with <.
+// Use OffsetBefore to compare offsets and subtraction to count the messages
+// between two offsets; both work across the wrap as long as the offsets are
+// less than 2^63 apart.
 type Message[T any] struct {
 	Val           T
 	Offset        uint64
 	LogAppendTime time.Time
 }
 
+// AppendResult type contains the offsets and the append time
+// assigned to messages added to a Queue. The messages got the
+// Count consecutive offsets starting from FirstOffset.
+type AppendResult struct {
+	FirstOffset   uint64
+	Count         uint64
+	LogAppendTime time.Time
+}
+
+// Returns the offsets assigned to the messages.
+func (res AppendResult) Offsets() []uint64 {
+	return offsetRange(res.FirstOffset, res.Count)
+}
+
+// Reports whether offset a comes before offset b, taking into account
+// that offsets wrap to 0 after MaxUint64 (serial number arithmetic).
+// The result is only meaningful if a and b are less than 2^63 apart.
+func OffsetBefore(a, b uint64) bool {
+	return int64(a-b) < 0
+}
+
 // QueueConfig type contains all the configuration options
 // for a Queue.
 type QueueConfig struct {
@@ -38,28 +79,162 @@ type QueueConfig struct {
 	retentionCount uint64
 	retentionTime  time.Duration
 	autoCleanup    bool
+	dedupWindow    time.Duration
+	producerTTL    time.Duration
+	retentionBytes uint64
+	sizeEstimator  SizeEstimator
+	clock          Clock
+	initialOffset  uint64
+
+	cleanupInterval time.Duration
+	cleanupCallback CleanupCallback
+
+	lagDepth    uint64
+	lagAge      time.Duration
+	lagCallback LagCallback
+}
+
+// Clock is the source of the current time for a Queue. It is used for
+// LogAppendTime, retentionTime, and the deduplication window, and its
+// tickers drive background cleanup.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a channel that receives the time every d, like
+	// the channel of a time.Ticker, and a function to stop the ticker.
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+// Clock that returns the actual current time. Used by default.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
 }
 
-// Linked list node. Used for Queue internals.
-type node[T any] struct {
-	message *Message[T]
-	next    *node[T]
+func (systemClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+// SizeEstimator is a function that estimates the size of a message value
+// in bytes. Used to enforce the retentionBytes of a Queue.
+type SizeEstimator func(val any) uint64
+
+// Sizer is implemented by message values that know their own size in bytes.
+type Sizer interface {
+	Size() int
+}
+
+// CleanupCallback is a function called with the name of a Queue and the
+// count of messages removed whenever background cleanup removes messages.
+type CleanupCallback func(queueName string, removed uint64)
+
+// LagCallback is a function called with the name of a Queue and its Lag
+// whenever the Queue starts (exceeded is true) or stops (exceeded is false)
+// exceeding its lag thresholds.
+type LagCallback func(queueName string, lag Lag, exceeded bool)
+
+// Deduplication or producer ID and the time it was accepted. Used for Queue internals.
+type dedupEntry struct {
+	id       string
+	accepted time.Time
 }
 
 // Queue[T] is a message queue that stores messages of type T (any).
 // Queue methods are safe to use concurrently in multiple goroutines.
+// Producers and consumers use separate locks, so adding messages does
+// not wait for reads and vice versa.
 //
 // When messages are Read() from a Queue, they are discarded. There is no
 // retention after a message has been read. It is possible to get a single
 // message without discarding/consuming it with the method PeekNext().
 //
+// After a Queue is closed with Close(), no more messages can be added to it,
+// but the remaining messages can still be read. Drain() closes the Queue and
+// waits until the remaining messages have been read.
+//
+// If the QueueConfig of a Queue has a positive cleanupInterval, the Queue
+// runs cleanup in a background goroutine until the Queue is closed.
+//
 // NOTE: never create a Queue directly; use NewQueue[T]() instead
 // to construct a Queue[T].
+//
+// Locking: the consumer side of the Queue (head*) is guarded by headMu and
+// the producer side (tail*, dedup*, producers) by tailMu. Fields shared by
+// both sides (config, closed, janitor*) are written with both locks held,
+// so holding either lock is enough to read them. When both locks are
+// needed, tailMu is always locked first.
 type Queue[T any] struct {
-	head   *node[T]
-	tail   *node[T]
+	id     uint64
 	config QueueConfig
-	mu     sync.Mutex
+	headMu sync.Mutex
+	tailMu sync.Mutex
+
+	// Messages are stored in a list of chunks; see chunk.
+	// headOffset is the offset of the next message to read, and
+	// tailOffset is the offset the next added message gets. Messages
+	// become visible to consumers when tailOffset is advanced past them.
+	head       *chunk[T]
+	headIndex  int
+	headOffset uint64
+	tail       *chunk[T]
+	tailIndex  int
+	tailOffset atomic.Uint64
+
+	// Emptied chunks kept for reuse; guarded by freeMu.
+	free      *chunk[T]
+	freeCount int
+	freeMu    sync.Mutex
+
+	// Total estimated size of the visible messages in the Queue in bytes.
+	// Read with bytesNoLock, since it can briefly be negative.
+	bytes atomic.Uint64
+
+	dedupIDs   map[string]time.Time
+	dedupOrder []dedupEntry
+
+	producers     map[string]producerState
+	producerOrder []dedupEntry
+
+	// Closed and replaced whenever messages are added or removed or the
+	// Queue is closed, to wake up goroutines waiting for the Queue to change.
+	// Created lazily by changed(); only replaced with notifyMu held, so
+	// that signal() needs no lock when nobody is waiting.
+	notify   atomic.Pointer[chan struct{}]
+	notifyMu sync.Mutex
+	closed   bool
+
+	// Channel to stop the current background cleanup goroutine; nil if
+	// there is no background cleanup.
+	janitorStop chan struct{}
+
+	// Counts of the background cleanup goroutines that have not returned,
+	// including stopped ones, and of those calling callbacks, and whether
+	// Close has been called; guarded by janitorMu. janitorCond is signaled
+	// when the counts change. See Close.
+	janitorsRunning int
+	janitorsCalling int
+	janitorsClosed  bool
+	janitorMu       sync.Mutex
+	janitorCond     sync.Cond
+
+	// Count of messages removed by cleanup and the distribution of
+	// read latencies, reported by Stats(); guarded by headMu.
+	removed     uint64
+	readLatency latencyHistogram
+
+	// Whether the Queue exceeded its lag thresholds at the last lag
+	// check and the count of changes to it; guarded by headMu.
+	lagExceeded bool
+	lagChanges  uint64
+
+	// Lag checks waiting for their LagCallback to be called, the count of
+	// changes already delivered, and whether a goroutine is delivering
+	// them; guarded by lagMu. See notifyLag.
+	lagPending    map[uint64]lagCheck
+	lagDelivered  uint64
+	lagDelivering bool
+	lagMu         sync.Mutex
 }
 
 // Function to create a default QueueConfig.
@@ -71,10 +246,37 @@ func DefaultConfig() QueueConfig {
 		retentionCount: uint64(1e9),
 		retentionTime:  time.Hour * 24,
 		autoCleanup:    false,
+		dedupWindow:    0,
+		producerTTL:    0,
+		retentionBytes: math.MaxUint64,
+		sizeEstimator:  EstimateSize,
+		clock:          systemClock{},
+
+		cleanupInterval: 0,
+		cleanupCallback: nil,
+
+		lagDepth:    0,
+		lagAge:      0,
+		lagCallback: nil,
 	}
 	return config
 }
 
+// Checks that all parameters of the QueueConfig are valid, e.g. that the
+// QueueConfig was not created directly without DefaultConfig.
+func (config QueueConfig) validate() error {
+	if config.retentionCount <= 0 || config.retentionTime <= 0 || config.dedupWindow < 0 || config.producerTTL < 0 || config.cleanupInterval < 0 || config.lagAge < 0 ||
+		config.retentionBytes <= 0 || config.sizeEstimator == nil || config.clock == nil {
+		return ErrInvalidConfig
+	}
+	return nil
+}
+
+// Returns the name in the QueueConfig.
+func (config QueueConfig) Name() string {
+	return config.name
+}
+
 // Returns a new QueueConfig with the name changed and other parameters kept the same.
 func (config QueueConfig) WithName(name string) (QueueConfig, error) {
 	config.name = name
@@ -99,55 +301,258 @@ func (config QueueConfig) WithRetentionTime(retentionTime time.Duration) (QueueC
 	return config, nil
 }
 
-// Returns a new QueueConfig with the name changed and other parameters kept the same.
+// Returns a new QueueConfig with the autoCleanup changed and other parameters kept the same.
 func (config QueueConfig) WithAutoCleanup(autoCleanup bool) (QueueConfig, error) {
 	config.autoCleanup = autoCleanup
 	return config, nil
 }
 
-// Function to initialize a new empty Queue with the default config.
-// To create a Queue for messages of type T, call NewQueue[T]().
-func NewQueue[T any]() *Queue[T] {
-	config := DefaultConfig()
-	msg := Message[T]{}
-	n := node[T]{
-		message: &msg,
+// Returns a new QueueConfig with the dedupWindow changed and other parameters kept the same.
+// Within dedupWindow, AddWithID and AddManyWithIDs drop messages whose deduplication ID
+// has already been accepted. A dedupWindow of 0 disables deduplication.
+func (config QueueConfig) WithDeduplicationWindow(dedupWindow time.Duration) (QueueConfig, error) {
+	if dedupWindow < 0 {
+		return config, ErrInvalidConfig
 	}
-	res := Queue[T]{
-		head:   &n,
-		tail:   &n,
-		config: config,
+	config.dedupWindow = dedupWindow
+	return config, nil
+}
+
+// Returns a new QueueConfig with the producerTTL changed and other parameters kept the same.
+// The Queue forgets the state of an idempotent producer ID when no batch has been added
+// with it for producerTTL. After that, the producer ID is new to the Queue again.
+// A producerTTL of 0 keeps the state until RemoveProducer is called.
+func (config QueueConfig) WithProducerTTL(producerTTL time.Duration) (QueueConfig, error) {
+	if producerTTL < 0 {
+		return config, ErrInvalidConfig
 	}
-	return &res
+	config.producerTTL = producerTTL
+	return config, nil
+}
+
+// Returns a new QueueConfig with the retentionBytes changed and other parameters kept the same.
+// Cleanup removes the oldest messages until the total size of the messages in the Queue,
+// as estimated by the sizeEstimator, is at most retentionBytes.
+// With the default retentionBytes of MaxUint64, sizes of messages are not estimated at all.
+func (config QueueConfig) WithRetentionBytes(retentionBytes uint64) (QueueConfig, error) {
+	if retentionBytes <= 0 {
+		return config, ErrInvalidConfig
+	}
+	config.retentionBytes = retentionBytes
+	return config, nil
+}
+
+// Returns a new QueueConfig with the sizeEstimator changed and other parameters kept the same.
+// The sizeEstimator is used to estimate the size of every message added to a Queue
+// if retentionBytes is limited.
+// The default sizeEstimator is EstimateSize.
+func (config QueueConfig) WithSizeEstimator(sizeEstimator SizeEstimator) (QueueConfig, error) {
+	if sizeEstimator == nil {
+		return config, ErrInvalidConfig
+	}
+	config.sizeEstimator = sizeEstimator
+	return config, nil
+}
+
+// Returns a new QueueConfig with the lag thresholds changed and other parameters kept the same.
+// A Queue exceeds its lag thresholds when it has more than depth messages or its oldest
+// message is older than age. A threshold of 0 is disabled.
+//
+// The lag is checked after messages are added, after messages are read while the
+// Queue exceeds its lag thresholds, on every background cleanup, and when CheckLag
+// is called. To notice old messages in a Queue that no messages are added to, use
+// a positive cleanupInterval or call CheckLag periodically.
+func (config QueueConfig) WithLagThresholds(depth uint64, age time.Duration) (QueueConfig, error) {
+	if age < 0 {
+		return config, ErrInvalidConfig
+	}
+	config.lagDepth = depth
+	config.lagAge = age
+	return config, nil
+}
+
+// Returns a new QueueConfig with the lagCallback changed and other parameters kept the same.
+// The lagCallback is called when a lag check finds that the Queue started or stopped
+// exceeding its lag thresholds. The lagCallback is called without the Queue locked,
+// one call at a time in the order of the changes, so it can use the Queue.
+func (config QueueConfig) WithLagCallback(lagCallback LagCallback) (QueueConfig, error) {
+	config.lagCallback = lagCallback
+	return config, nil
+}
+
+// Returns a new QueueConfig with the clock changed and other parameters kept the same.
+// By default, a Queue uses the actual current time. A different Clock can be used,
+// e.g. to control time in tests. Background cleanup keeps using the ticker of the
+// Clock it was started with until UpdateConfig changes the cleanupInterval.
+func (config QueueConfig) WithClock(clock Clock) (QueueConfig, error) {
+	if clock == nil {
+		return config, ErrInvalidConfig
+	}
+	config.clock = clock
+	return config, nil
+}
+
+// Returns a new QueueConfig with the initialOffset changed and other parameters kept the same.
+// The first message added to a Queue created with this config gets the offset initialOffset.
+// Can be used e.g. to restore a Queue that was at a high offset.
+func (config QueueConfig) WithInitialOffset(initialOffset uint64) (QueueConfig, error) {
+	config.initialOffset = initialOffset
+	return config, nil
+}
+
+// Function to estimate the size of a message value in bytes.
+// For strings and byte slices, returns their length. For values that
+// implement Sizer, returns the result of their Size method. For other
+// values, returns the size of the value itself, not including any
+// memory it references.
+func EstimateSize(val any) uint64 {
+	switch v := val.(type) {
+	case nil:
+		return 0
+	case string:
+		return uint64(len(v))
+	case []byte:
+		return uint64(len(v))
+	case Sizer:
+		return uint64(max(v.Size(), 0))
+	default:
+		return uint64(reflect.TypeOf(val).Size())
+	}
+}
+
+// Returns a new QueueConfig with the cleanupInterval changed and other parameters kept the same.
+// If cleanupInterval is positive, a Queue runs cleanup in a background goroutine every
+// cleanupInterval until the Queue is closed. A cleanupInterval of 0 disables background cleanup.
+func (config QueueConfig) WithCleanupInterval(cleanupInterval time.Duration) (QueueConfig, error) {
+	if cleanupInterval < 0 {
+		return config, ErrInvalidConfig
+	}
+	config.cleanupInterval = cleanupInterval
+	return config, nil
+}
+
+// Returns a new QueueConfig with the cleanupCallback changed and other parameters kept the same.
+// The cleanupCallback is called whenever background cleanup removes messages.
+func (config QueueConfig) WithCleanupCallback(cleanupCallback CleanupCallback) (QueueConfig, error) {
+	config.cleanupCallback = cleanupCallback
+	return config, nil
 }
 
 // Function to initialize a new empty Queue with the default config.
+// To create a Queue for messages of type T, call NewQueue[T]().
+func NewQueue[T any]() *Queue[T] {
+	return NewQueueWithConfig[T](DefaultConfig())
+}
+
+// Function to initialize a new empty Queue with the given config.
 // To create a Queue for messages of type T, call NewQueueWithConfig[T]().
 func NewQueueWithConfig[T any](config QueueConfig) *Queue[T] {
-	msg := Message[T]{}
-	n := node[T]{
-		message: &msg,
+	// Fill in the parameters a QueueConfig created directly is missing;
+	// such a QueueConfig has no byte retention.
+	if config.sizeEstimator == nil {
+		config.sizeEstimator = EstimateSize
 	}
+	if config.retentionBytes == 0 {
+		config.retentionBytes = math.MaxUint64
+	}
+	if config.clock == nil {
+		config.clock = systemClock{}
+	}
+	c := new(chunk[T])
 	res := Queue[T]{
-		head:   &n,
-		tail:   &n,
-		config: config,
+		id:         queueIDs.Add(1),
+		head:       c,
+		tail:       c,
+		headOffset: config.initialOffset,
+		config:     config,
 	}
+	res.tailOffset.Store(config.initialOffset)
+	res.janitorCond.L = &res.janitorMu
+	res.startJanitorNoLock()
 	return &res
 }
 
+// The id of a Queue is set by the constructor and never changes,
+// so checking it needs no lock.
 func (q *Queue[T]) isProperlyInitialized() bool {
-	return q.tail != nil
+	return q.id != 0
+}
+
+// Internal method to lock both the producer and the consumer side of the Queue.
+func (q *Queue[T]) lockAll() {
+	q.tailMu.Lock()
+	q.headMu.Lock()
 }
 
+// Internal method to unlock both the producer and the consumer side of the Queue.
+func (q *Queue[T]) unlockAll() {
+	q.headMu.Unlock()
+	q.tailMu.Unlock()
+}
+
+// Returns a copy of the QueueConfig of the Queue.
 func (q *Queue[T]) GetConfig() QueueConfig {
+	q.headMu.Lock()
+	defer q.headMu.Unlock()
+
 	return q.config
 }
 
+// Method to change the configuration of the Queue without losing messages.
+// If retentionCount, retentionTime, or retentionBytes shrinks, runs cleanup immediately.
+// Sizes of messages already in the Queue are not re-estimated if sizeEstimator changes.
+// If cleanupInterval changes, restarts background cleanup with the new interval.
+//
+// If a parameter of config is invalid or the name or initialOffset in config
+// differs from that of the Queue, returns the error ErrInvalidConfig.
+func (q *Queue[T]) UpdateConfig(config QueueConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	if !q.isProperlyInitialized() {
+		return ErrImproperlyInitializedQueue
+	}
+
+	q.lockAll()
+	defer q.unlockAll()
+
+	if config.name != q.config.name || config.initialOffset != q.config.initialOffset {
+		return ErrInvalidConfig
+	}
+
+	shrinks := config.retentionCount < q.config.retentionCount || config.retentionTime < q.config.retentionTime ||
+		config.retentionBytes < q.config.retentionBytes
+	restartJanitor := config.cleanupInterval != q.config.cleanupInterval
+	resize := (config.retentionBytes == math.MaxUint64) != (q.config.retentionBytes == math.MaxUint64)
+	reorderProducers := (config.producerTTL > 0) != (q.config.producerTTL > 0)
+	q.config = config
+	if resize {
+		q.resizeNoLock()
+	}
+	if reorderProducers {
+		q.reorderProducersNoLock()
+	}
+	if shrinks {
+		q.cleanup()
+	}
+	if restartJanitor {
+		// The old goroutine is not waited for, since it might be
+		// waiting for the locks held here. It returns without running
+		// cleanup once it sees that it is no longer the current one.
+		if q.janitorStop != nil {
+			close(q.janitorStop)
+			q.janitorStop = nil
+		}
+		q.startJanitorNoLock()
+	}
+
+	return nil
+}
+
 // Checks if the Queue is empty.
 func (q *Queue[T]) IsEmpty() (bool, error) {
-	q.mu.Lock()
-	defer q.mu.Unlock()
+	q.headMu.Lock()
+	defer q.headMu.Unlock()
 
 	if !q.isProperlyInitialized() {
 		return false, ErrImproperlyInitializedQueue
@@ -161,16 +566,16 @@ func (q *Queue[T]) IsEmpty() (bool, error) {
 }
 
 // Internal method to check if the Queue is empty.
-// Does not lock the Queue; assumes that the Queue is already
-// locked when this function is called.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
 func (q *Queue[T]) isEmptyNoLock() bool {
-	return q.head.message.Offset == q.tail.message.Offset
+	return q.headOffset == q.tailOffset.Load()
 }
 
 // Returns the length of the Queue.
 func (q *Queue[T]) Length() (uint64, error) {
-	q.mu.Lock()
-	defer q.mu.Unlock()
+	q.headMu.Lock()
+	defer q.headMu.Unlock()
 
 	if !q.isProperlyInitialized() {
 		return 0, ErrImproperlyInitializedQueue
@@ -184,10 +589,10 @@ func (q *Queue[T]) Length() (uint64, error) {
 }
 
 // Internal method to get the length of the Queue.
-// Does not lock the Queue; assumes that the Queue is already
-// locked when this function is called.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
 func (q *Queue[T]) lengthNoLock() uint64 {
-	return q.tail.message.Offset - q.head.message.Offset
+	return q.tailOffset.Load() - q.headOffset
 }
 
 // Method to add a single message to the Queue.
@@ -199,33 +604,185 @@ func (q *Queue[T]) Add(val T) error {
 //
 // If the Queue has been improperly initialized, i.e. created manually,
 // returns the error ErrImproperlyInitializedQueue.
+// If the Queue has been closed, returns the error ErrQueueClosed.
 func (q *Queue[T]) AddMany(vals []T) error {
-	q.mu.Lock()
-	defer q.mu.Unlock()
+	_, err := q.AppendMany(vals)
+	return err
+}
 
+// Method to add a single message to the Queue.
+// Returns the offset and the append time assigned to the message.
+func (q *Queue[T]) Append(val T) (AppendResult, error) {
+	return q.AppendMany([]T{val})
+}
+
+// Method to add multiple messages to the Queue.
+// Returns the offsets and the append time assigned to the messages.
+//
+// If the Queue has been improperly initialized, i.e. created manually,
+// returns the error ErrImproperlyInitializedQueue.
+// If the Queue has been closed, returns the error ErrQueueClosed.
+func (q *Queue[T]) AppendMany(vals []T) (AppendResult, error) {
 	if !q.isProperlyInitialized() {
-		return ErrImproperlyInitializedQueue
+		return AppendResult{}, ErrImproperlyInitializedQueue
 	}
 
-	appendTime := time.Now()
-	for _, val := range vals {
-		q.tail.message.Val = val
-		q.tail.message.LogAppendTime = appendTime
+	q.tailMu.Lock()
+	if q.closed {
+		q.tailMu.Unlock()
+		return AppendResult{}, ErrQueueClosed
+	}
+	res := q.addManyNoLock(vals, q.config.clock.Now())
+	afterAdd := q.afterAddNeededNoLock()
+	q.tailMu.Unlock()
+
+	if afterAdd {
+		q.afterAdd()
+	}
+
+	return res, nil
+}
+
+// Internal method to append messages to the tail of the Queue.
+// The messages become visible to consumers all at once.
+// Does not lock the Queue; assumes that tailMu is already
+// held when this function is called.
+func (q *Queue[T]) addManyNoLock(vals []T, appendTime time.Time) AppendResult {
+	res := AppendResult{
+		FirstOffset:   q.tailOffset.Load(),
+		Count:         uint64(len(vals)),
+		LogAppendTime: appendTime,
+	}
+	// Estimating sizes can allocate, so it is skipped when it is not needed.
+	estimate := q.sizesNeededNoLock()
+	batchBytes := uint64(0)
+	for i, val := range vals {
 		msg := Message[T]{
-			Offset: q.tail.message.Offset + 1,
+			Val:           val,
+			Offset:        res.FirstOffset + uint64(i),
+			LogAppendTime: appendTime,
 		}
-		n := node[T]{
-			message: &msg,
+		var size uint64
+		if estimate {
+			size = q.config.sizeEstimator(val)
 		}
-		q.tail.next = &n
-		q.tail = &n
+		q.pushNoLock(msg, size)
+		batchBytes += size
 	}
+	if res.Count > 0 {
+		// The batch is counted in bytes only after it is visible, so
+		// that cleanup never removes messages to make room for it early.
+		q.tailOffset.Add(res.Count)
+		q.bytes.Add(batchBytes)
+		q.signal()
+	}
+	return res
+}
+
+// Internal method to check whether automatic cleanup or a lag check
+// should run after messages were added.
+// Does not lock the Queue; assumes that tailMu is already
+// held when this function is called.
+func (q *Queue[T]) afterAddNeededNoLock() bool {
+	return q.config.autoCleanup || q.config.lagCallback != nil
+}
 
+// Internal method to run automatic cleanup and check the lag after
+// messages were added. Locks headMu; assumes that tailMu is not held
+// when this function is called, so that adding messages does not wait
+// for consumers unless automatic cleanup or a lagCallback is enabled.
+func (q *Queue[T]) afterAdd() {
+	q.headMu.Lock()
 	if q.config.autoCleanup {
 		q.cleanup()
 	}
+	check := q.checkLagNoLock()
+	q.headMu.Unlock()
 
-	return nil
+	q.notifyLag(check)
+}
+
+// Method to add a single message with a deduplication ID to the Queue.
+// Returns true if the message was dropped as a duplicate.
+//
+// See AddManyWithIDs for details.
+func (q *Queue[T]) AddWithID(id string, val T) (bool, error) {
+	dropped, err := q.AddManyWithIDs([]string{id}, []T{val})
+	if err != nil {
+		return false, err
+	}
+	return len(dropped) > 0, nil
+}
+
+// Method to add multiple messages with producer-supplied deduplication IDs
+// to the Queue. ids[i] is the deduplication ID of vals[i].
+//
+// If the deduplication window of the Queue is positive, messages whose ID
+// has already been accepted within the window are silently dropped. This
+// includes repeated IDs within vals. Returns the IDs of the dropped messages.
+// If the deduplication window is 0, all messages are added.
+//
+// If ids and vals have different lengths, returns the error ErrMismatchedIDs.
+// If the Queue has been closed, returns the error ErrQueueClosed.
+func (q *Queue[T]) AddManyWithIDs(ids []string, vals []T) ([]string, error) {
+	if len(ids) != len(vals) {
+		return nil, ErrMismatchedIDs
+	}
+	if !q.isProperlyInitialized() {
+		return nil, ErrImproperlyInitializedQueue
+	}
+
+	q.tailMu.Lock()
+	if q.closed {
+		q.tailMu.Unlock()
+		return nil, ErrQueueClosed
+	}
+
+	appendTime := q.config.clock.Now()
+	var dropped []string
+	if q.config.dedupWindow > 0 {
+		q.pruneDedupIDs(appendTime)
+		accepted := make([]T, 0, len(vals))
+		for i, id := range ids {
+			if _, ok := q.dedupIDs[id]; ok {
+				dropped = append(dropped, id)
+				continue
+			}
+			q.dedupIDs[id] = appendTime
+			q.dedupOrder = append(q.dedupOrder, dedupEntry{id: id, accepted: appendTime})
+			accepted = append(accepted, vals[i])
+		}
+		vals = accepted
+	}
+
+	q.addManyNoLock(vals, appendTime)
+	afterAdd := q.afterAddNeededNoLock()
+	q.tailMu.Unlock()
+
+	if afterAdd {
+		q.afterAdd()
+	}
+
+	return dropped, nil
+}
+
+// Internal method to forget deduplication IDs that were accepted
+// longer than dedupWindow ago.
+// Does not lock the Queue; assumes that tailMu is already
+// held when this function is called.
+func (q *Queue[T]) pruneDedupIDs(currTime time.Time) {
+	if q.dedupIDs == nil {
+		q.dedupIDs = make(map[string]time.Time)
+	}
+	expired := 0
+	for _, entry := range q.dedupOrder {
+		if currTime.Sub(entry.accepted) < q.config.dedupWindow {
+			break
+		}
+		delete(q.dedupIDs, entry.id)
+		expired++
+	}
+	q.dedupOrder = q.dedupOrder[expired:]
 }
 
 // Method to read a single message from the Queue.
@@ -246,55 +803,156 @@ func (q *Queue[T]) ReadMany(limit int) ([]Message[T], error) {
 	if limit <= 0 {
 		return []Message[T]{}, ErrInvalidLimit
 	}
-	q.mu.Lock()
-	defer q.mu.Unlock()
-
 	if !q.isProperlyInitialized() {
 		return []Message[T]{}, ErrImproperlyInitializedQueue
 	}
 
-	if q.isEmptyNoLock() {
-		return []Message[T]{}, ErrQueueIsEmpty
-	}
+	q.headMu.Lock()
+	res, check, err := q.readManyNoLock(limit)
+	q.headMu.Unlock()
+
+	q.notifyLag(check)
+	return res, err
+}
 
+// Internal method to read multiple messages from the Queue.
+// If the Queue exceeded its lag thresholds, checks the lag again after
+// reading; the returned lagCheck must be passed to notifyLag after
+// unlocking the Queue.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
+func (q *Queue[T]) readManyNoLock(limit int) ([]Message[T], lagCheck, error) {
 	if q.config.autoCleanup {
 		q.cleanup()
 	}
 
+	if q.isEmptyNoLock() {
+		return []Message[T]{}, lagCheck{}, ErrQueueIsEmpty
+	}
+
 	length := q.lengthNoLock()
 	if length <= math.MaxInt {
 		limit = min(limit, int(length))
 	}
 	res := make([]Message[T], limit)
-	node := q.head
+	readTime := q.config.clock.Now()
 	for i := 0; i < limit; i++ {
-		res[i] = *node.message
-		node = node.next
+		res[i] = *q.peekNoLock()
+		q.readLatency.observe(readTime.Sub(res[i].LogAppendTime))
+		q.popNoLock()
+	}
+	q.signal()
+
+	var check lagCheck
+	if q.lagExceeded {
+		check = q.checkLagNoLock()
+	}
+	return res, check, nil
+}
+
+// Method to read a single message from the Queue, waiting for one
+// to be added if the Queue is empty.
+func (q *Queue[T]) ReadWait(ctx context.Context) (Message[T], error) {
+	res, err := q.ReadManyWait(ctx, 1)
+	if err != nil {
+		return Message[T]{}, err
+	}
+	return res[0], nil
+}
+
+// Method to read multiple messages from the Queue, waiting for messages
+// to be added if the Queue is empty. Reads at most `limit` messages.
+//
+// If `limit` is non-positive, returns the error ErrInvalidLimit.
+// If ctx is done before any messages are added, returns the error of ctx,
+// e.g. context.DeadlineExceeded.
+// If the Queue is closed and empty, returns the error ErrQueueClosed.
+func (q *Queue[T]) ReadManyWait(ctx context.Context, limit int) ([]Message[T], error) {
+	if limit <= 0 {
+		return []Message[T]{}, ErrInvalidLimit
+	}
+	if !q.isProperlyInitialized() {
+		return []Message[T]{}, ErrImproperlyInitializedQueue
+	}
+	var notify <-chan struct{}
+	for {
+		q.headMu.Lock()
+		res, check, err := q.readManyNoLock(limit)
+		closed := q.closed
+		q.headMu.Unlock()
+		q.notifyLag(check)
+
+		if err != ErrQueueIsEmpty {
+			return res, err
+		}
+		if closed {
+			return []Message[T]{}, ErrQueueClosed
+		}
+		if notify == nil {
+			// Get the channel and read again before waiting so that
+			// no messages added in between are missed.
+			notify = q.changed()
+			continue
+		}
+
+		select {
+		case <-notify:
+			notify = nil
+		case <-ctx.Done():
+			return []Message[T]{}, ctx.Err()
+		}
+	}
+}
+
+// Internal method to get a channel that is closed the next time
+// the Queue changes.
+func (q *Queue[T]) changed() <-chan struct{} {
+	q.notifyMu.Lock()
+	defer q.notifyMu.Unlock()
+
+	notify := q.notify.Load()
+	if notify == nil {
+		ch := make(chan struct{})
+		notify = &ch
+		q.notify.Store(notify)
+	}
+	return *notify
+}
+
+// Internal method to wake up all goroutines waiting for the Queue to change.
+func (q *Queue[T]) signal() {
+	if q.notify.Load() == nil {
+		return
+	}
+
+	q.notifyMu.Lock()
+	defer q.notifyMu.Unlock()
+
+	if notify := q.notify.Swap(nil); notify != nil {
+		close(*notify)
 	}
-	q.head = node
-	return res, nil
 }
 
 // Method to get the next message without consuming it like Read does.
 //
 // If the Queue is empty, returns the error ErrQueueIsEmpty.
 func (q *Queue[T]) PeekNext() (Message[T], error) {
-	q.mu.Lock()
-	defer q.mu.Unlock()
-
 	if !q.isProperlyInitialized() {
 		return Message[T]{}, ErrImproperlyInitializedQueue
 	}
 
-	if q.isEmptyNoLock() {
-		return Message[T]{}, ErrQueueIsEmpty
-	}
+	q.headMu.Lock()
+	defer q.headMu.Unlock()
 
 	if q.config.autoCleanup {
 		q.cleanup()
 	}
 
-	return *q.head.message, nil
+	if q.isEmptyNoLock() {
+		return Message[T]{}, ErrQueueIsEmpty
+	}
+
+	return *q.peekNoLock(), nil
 }
 
 // TODO
@@ -304,23 +962,24 @@ func (q *Queue[T]) PeekLast() (Message[T], error) {
 	return Message[T]{}, ErrUnimplementedMethod
 }
 
-// Remove messages until there are at most retentionCount messages
-// and remove messages that are older than retentionTime.
+// Remove messages until there are at most retentionCount messages,
+// remove messages that are older than retentionTime, and remove messages
+// until their total size is at most retentionBytes.
 // Returns the count of deleted messages.
 func (q *Queue[T]) Cleanup() (uint64, error) {
-	q.mu.Lock()
-	defer q.mu.Unlock()
-
 	if !q.isProperlyInitialized() {
 		return 0, ErrImproperlyInitializedQueue
 	}
 
+	q.headMu.Lock()
+	defer q.headMu.Unlock()
+
 	return q.cleanup(), nil
 }
 
 // Internal method to run cleanup on the Queue.
-// Does not lock the Queue; assumes that the Queue is already
-// locked when this function is called.
+// Does not lock the Queue; assumes that headMu is already
+// held when this function is called.
 // Returns the count of deleted messages.
 func (q *Queue[T]) cleanup() uint64 {
 	removed := uint64(0)
@@ -332,19 +991,92 @@ func (q *Queue[T]) cleanup() uint64 {
 		toRemove = length - retentionCount
 	}
 	removed += toRemove
-	node := q.head
 	for i := uint64(0); i < toRemove; i++ {
-		node = node.next
+		q.popNoLock()
 	}
-	q.head = node
 
-	currTime := time.Now()
+	currTime := q.config.clock.Now()
 	retentionTime := q.config.retentionTime
-	tailOffset := q.tail.message.Offset
-	for q.head.message.Offset < tailOffset && currTime.Sub(q.head.message.LogAppendTime) > retentionTime {
+	for !q.isEmptyNoLock() && currTime.Sub(q.peekNoLock().LogAppendTime) > retentionTime {
+		removed++
+		q.popNoLock()
+	}
+
+	for !q.isEmptyNoLock() && q.bytesNoLock() > q.config.retentionBytes {
 		removed++
-		q.head = q.head.next
+		q.popNoLock()
 	}
 
+	if removed > 0 {
+		q.removed += removed
+		q.signal()
+	}
 	return removed
 }
+
+// Method to close the Queue. After Close, adding messages to the Queue
+// returns the error ErrQueueClosed, goroutines waiting for messages in
+// ReadWait or ReadManyWait on an empty Queue are woken up, and background
+// cleanup is stopped. The remaining messages can still be read.
+// Calling Close more than once does nothing.
+//
+// After Close returns, the CleanupCallback of the Queue is no longer called.
+// Only a call that was already in progress when Close was called, e.g. the
+// call calling Close, can still be running.
+// Queues with background cleanup should always be closed when no longer
+// needed, since the background goroutine keeps the Queue from being
+// garbage collected.
+func (q *Queue[T]) Close() error {
+	if !q.isProperlyInitialized() {
+		return ErrImproperlyInitializedQueue
+	}
+
+	q.lockAll()
+	q.closed = true
+	if q.janitorStop != nil {
+		close(q.janitorStop)
+		q.janitorStop = nil
+	}
+	q.unlockAll()
+	q.signal()
+
+	// Wait for the background cleanup goroutines without holding the locks,
+	// since they might be waiting for headMu to run cleanup. Goroutines
+	// calling callbacks are not waited for, since Close might have been
+	// called from a callback; they make no further calls to the
+	// CleanupCallback once janitorsClosed is set.
+	q.janitorMu.Lock()
+	q.janitorsClosed = true
+	for q.janitorsRunning > q.janitorsCalling {
+		q.janitorCond.Wait()
+	}
+	q.janitorMu.Unlock()
+	return nil
+}
+
+// Method to close the Queue and wait until all remaining messages
+// have been read from it.
+//
+// If ctx is done before the Queue is empty, returns the error of ctx,
+// e.g. context.DeadlineExceeded. The Queue stays closed.
+func (q *Queue[T]) Drain(ctx context.Context) error {
+	if err := q.Close(); err != nil {
+		return err
+	}
+	for {
+		notify := q.changed()
+		q.headMu.Lock()
+		empty := q.isEmptyNoLock()
+		q.headMu.Unlock()
+
+		if empty {
+			return nil
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"slices"
@@ -36,11 +37,35 @@ func TestQueue(t *testing.T) {
 		_, err = q.ReadMany(10)
 		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "ReadMany() on a manually created queue returned incorrect error", false)
 
+		_, err = q.ReadWait(context.Background())
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "ReadWait() on a manually created queue returned incorrect error", false)
+
 		_, err = q.PeekNext()
 		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "PeekNext() on a manually created queue returned incorrect error", false)
 
 		_, err = q.Cleanup()
 		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "Cleanup() on a manually created queue returned incorrect error", false)
+
+		_, err = q.Append("asd")
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "Append() on a manually created queue returned incorrect error", false)
+
+		_, err = q.AppendMany([]string{"asd"})
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "AppendMany() on a manually created queue returned incorrect error", false)
+
+		err = q.Close()
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "Close() on a manually created queue returned incorrect error", false)
+
+		err = q.Drain(context.Background())
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "Drain() on a manually created queue returned incorrect error", false)
+
+		err = q.UpdateConfig(DefaultConfig())
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "UpdateConfig() on a manually created queue returned incorrect error", false)
+
+		_, err = q.AddWithID("id", "asd")
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "AddWithID() on a manually created queue returned incorrect error", false)
+
+		_, err = q.AddManyWithIDs([]string{"id"}, []string{"asd"})
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "AddManyWithIDs() on a manually created queue returned incorrect error", false)
 	})
 
 	t.Run("concurrent Add() and Read()", func(t *testing.T) {
@@ -56,7 +81,7 @@ func TestQueue(t *testing.T) {
 			}(q, &wg)
 		}
 		wg.Wait()
-		testutil.AssertEqual(t, q.tail.message.Offset, uint64(Iterations), fmt.Sprintf("After %d Add() calls, incorrect offset", Iterations), false)
+		testutil.AssertEqual(t, q.tailOffset.Load(), uint64(Iterations), fmt.Sprintf("After %d Add() calls, incorrect offset", Iterations), false)
 
 		// Test that we can concurrently Read() all values
 		vals := make([]int, Iterations)
@@ -92,6 +117,27 @@ func TestQueue(t *testing.T) {
 		}
 	})
 
+	t.Run("test Add() does not wait for consumers", func(t *testing.T) {
+		q := NewQueue[int]()
+
+		// Hold the consumer lock as if a consumer was in the middle of a read.
+		q.headMu.Lock()
+		added := make(chan error)
+		go func() {
+			added <- q.Add(1)
+		}()
+		select {
+		case err := <-added:
+			testutil.AssertEqual(t, err, nil, "Add() returned an unexpected error", false)
+		case <-time.After(time.Second * 5):
+			t.Error("Add() waited for the consumer lock")
+		}
+		q.headMu.Unlock()
+
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 1, "1 message in Queue, incorrect Length()", false)
+	})
+
 	t.Run("test AddMany and ReadMany", func(t *testing.T) {
 		q := NewQueue[string]()
 
@@ -132,6 +178,133 @@ func TestQueue(t *testing.T) {
 		testutil.AssertDeepEqual(t, gotVals, expected, fmt.Sprintf("ReadMany(%d) returned incorrect result", Iterations), false)
 	})
 
+	t.Run("test Append and AppendMany", func(t *testing.T) {
+		q := NewQueue[string]()
+
+		res, err := q.Append("asd")
+		testutil.AssertEqual(t, err, nil, "Append() returned an unexpected error", false)
+		testutil.AssertEqual(t, res.FirstOffset, 0, "Append() returned incorrect FirstOffset", false)
+		testutil.AssertEqual(t, res.Count, 1, "Append() returned incorrect Count", false)
+
+		res, err = q.AppendMany([]string{"a", "b", "c"})
+		testutil.AssertEqual(t, err, nil, "AppendMany() returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, res.Offsets(), []uint64{1, 2, 3}, "AppendMany() returned incorrect offsets", false)
+		testutil.AssertEqual(t, len(res.Offsets()), 3, "AppendMany() returned incorrect amount of offsets", false)
+
+		_, _ = q.Read()
+		msg, _ := q.Read()
+		testutil.AssertEqual(t, msg.Offset, res.FirstOffset, "Read() returned a message with an offset different from the one AppendMany() returned", false)
+		testutil.AssertEqual(t, msg.LogAppendTime, res.LogAppendTime, "Read() returned a message with a LogAppendTime different from the one AppendMany() returned", false)
+
+		res, err = q.AppendMany([]string{})
+		testutil.AssertEqual(t, err, nil, "AppendMany() with no messages returned an unexpected error", false)
+		testutil.AssertEqual(t, res.Count, 0, "AppendMany() with no messages returned incorrect Count", false)
+		testutil.AssertEqual(t, res.FirstOffset, 4, "AppendMany() with no messages returned incorrect FirstOffset", false)
+	})
+
+	t.Run("test AddWithID and AddManyWithIDs deduplication", func(t *testing.T) {
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithDeduplicationWindow(time.Millisecond * 50)
+		config, _ = config.WithClock(clock)
+		q := NewQueueWithConfig[string](config)
+
+		_, err := q.AddManyWithIDs([]string{"a"}, []string{"asd", "dsa"})
+		testutil.AssertEqual(t, err, ErrMismatchedIDs, "AddManyWithIDs() with mismatched lengths returned incorrect error", false)
+
+		dropped, err := q.AddManyWithIDs([]string{"a", "b", "a"}, []string{"asd", "dsa", "asd"})
+		testutil.AssertEqual(t, err, nil, "AddManyWithIDs() returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, dropped, []string{"a"}, "AddManyWithIDs() reported incorrect dropped IDs", false)
+		testutil.AssertEqual(t, len(dropped), 1, "AddManyWithIDs() reported incorrect amount of dropped IDs", false)
+
+		duplicate, err := q.AddWithID("b", "dsa")
+		testutil.AssertEqual(t, err, nil, "AddWithID() returned an unexpected error", false)
+		testutil.AssertEqual(t, duplicate, true, "AddWithID() with an already accepted ID was not dropped", false)
+
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 2, "duplicates were added to the queue, incorrect Length()", false)
+
+		clock.Advance(time.Millisecond * 60)
+		duplicate, err = q.AddWithID("a", "asd")
+		testutil.AssertEqual(t, err, nil, "AddWithID() returned an unexpected error", false)
+		testutil.AssertEqual(t, duplicate, false, "AddWithID() with an expired ID was dropped", false)
+
+		got, _ = q.Length()
+		testutil.AssertEqual(t, got, 3, "message with an expired ID was not added to the queue, incorrect Length()", false)
+
+		noDedupQueue := NewQueue[string]()
+		dropped, _ = noDedupQueue.AddManyWithIDs([]string{"a", "a"}, []string{"asd", "asd"})
+		testutil.AssertEqual(t, len(dropped), 0, "deduplication is disabled, but AddManyWithIDs() dropped messages", false)
+		got, _ = noDedupQueue.Length()
+		testutil.AssertEqual(t, got, 2, "deduplication is disabled, incorrect Length()", false)
+	})
+
+	t.Run("test LogAppendTime comes from the configured Clock", func(t *testing.T) {
+		clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		config, _ := DefaultConfig().WithClock(clock)
+		q := NewQueueWithConfig[string](config)
+
+		res, err := q.Append("asd")
+		testutil.AssertEqual(t, err, nil, "Append() returned an unexpected error", false)
+		testutil.AssertEqual(t, res.LogAppendTime, clock.Now(), "Append() returned incorrect LogAppendTime", false)
+
+		clock.Advance(time.Hour)
+		tx := BeginTx()
+		_ = TxAdd(tx, q, "dsa")
+		_ = tx.Commit()
+
+		_, _ = q.Read()
+		msg, _ := q.Read()
+		testutil.AssertEqual(t, msg.LogAppendTime, clock.Now(), "message added in a transaction has incorrect LogAppendTime", false)
+	})
+
+	t.Run("test ReadWait and ReadManyWait", func(t *testing.T) {
+		q := NewQueue[string]()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		_, err := q.ReadWait(ctx)
+		testutil.AssertEqual(t, err, context.DeadlineExceeded, "ReadWait() on an empty queue returned incorrect error", false)
+
+		_, err = q.ReadManyWait(context.Background(), 0)
+		testutil.AssertEqual(t, err, ErrInvalidLimit, "ReadManyWait(0) returned an incorrect error", false)
+
+		expected := []string{"asd", "dsa"}
+		go func() {
+			time.Sleep(time.Millisecond * 10)
+			_ = q.AddMany(expected)
+		}()
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		got, err := q.ReadManyWait(ctx, 10)
+		gotVals := make([]string, len(got))
+		for i, msg := range got {
+			gotVals[i] = msg.Val
+		}
+		testutil.AssertEqual(t, err, nil, "ReadManyWait() returned an unexpected error after messages were added", false)
+		testutil.AssertEqual(t, len(gotVals), len(expected), "ReadManyWait() returned incorrect amount of messages", false)
+		testutil.AssertDeepEqual(t, gotVals, expected, "ReadManyWait() returned an incorrect result", false)
+
+		// Test that concurrent waiting readers get every message exactly once
+		var wg sync.WaitGroup
+		vals := make([]int, 100)
+		for i := range vals {
+			wg.Add(1)
+			go func(index int) {
+				msg, _ := q.ReadWait(ctx)
+				vals[index], _ = strconv.Atoi(msg.Val)
+				wg.Done()
+			}(i)
+		}
+		for i := range vals {
+			_ = q.Add(strconv.Itoa(i))
+		}
+		wg.Wait()
+		slices.Sort(vals)
+		for i, val := range vals {
+			testutil.AssertEqual(t, val, i, "concurrent ReadWait() calls returned incorrect values", true)
+		}
+	})
+
 	t.Run("test IsEmpty()", func(t *testing.T) {
 		q := NewQueue[string]()
 
@@ -227,8 +400,8 @@ func TestQueue(t *testing.T) {
 
 		expected := "asd"
 		q.Add(expected)
-		q.head.message.Offset = math.MaxUint64 - 1
-		q.tail.message.Offset = math.MaxUint64
+		q.headOffset = math.MaxUint64 - 1
+		q.tailOffset.Store(math.MaxUint64)
 
 		got, _ := q.Length()
 		testutil.AssertEqual(t, got, 1, "1 message in Queue, offset overflowing, incorrect Length()", false)
@@ -247,7 +420,7 @@ func TestQueue(t *testing.T) {
 
 		q.Add(expected)
 
-		testutil.AssertEqual(t, q.tail.message.Offset, 0, "q.tail.message.Offset is incorrect after overflowing", false)
+		testutil.AssertEqual(t, q.tailOffset.Load(), 0, "q.tailOffset is incorrect after overflowing", false)
 
 		got, _ = q.Length()
 		testutil.AssertEqual(t, got, 1, "1 message in Queue, offset overflowing, incorrect Length()", false)
@@ -266,7 +439,7 @@ func TestQueue(t *testing.T) {
 
 		q.Add(expected)
 
-		testutil.AssertEqual(t, q.tail.message.Offset, 1, "q.tail.message.Offset is incorrect after overflowing", false)
+		testutil.AssertEqual(t, q.tailOffset.Load(), 1, "q.tailOffset is incorrect after overflowing", false)
 
 		got, _ = q.Length()
 		testutil.AssertEqual(t, got, 1, "1 message in Queue, offset overflowing, incorrect Length()", false)
@@ -283,6 +456,66 @@ func TestQueue(t *testing.T) {
 		testutil.AssertEqual(t, err, nil, "IsEmpty() returned an unexpected error", false)
 		testutil.AssertEqual(t, flag, true, "IsEmpty() returned an incorrect value after overflowing offset", false)
 	})
+
+	t.Run("test OffsetBefore", func(t *testing.T) {
+		testTable := []struct {
+			a, b     uint64
+			expected bool
+		}{
+			{0, 1, true},
+			{1, 0, false},
+			{5, 5, false},
+			{math.MaxUint64, 0, true},
+			{0, math.MaxUint64, false},
+			{math.MaxUint64 - 10, 10, true},
+			{10, math.MaxUint64 - 10, false},
+		}
+
+		for _, test := range testTable {
+			got := OffsetBefore(test.a, test.b)
+			testutil.AssertEqual(t, got, test.expected, fmt.Sprintf("OffsetBefore(%d, %d) returned incorrect value", test.a, test.b), true)
+		}
+	})
+
+	t.Run("test initial offset and wraparound", func(t *testing.T) {
+		config, _ := DefaultConfig().WithInitialOffset(math.MaxUint64 - 2)
+		config, _ = config.WithRetentionCount(4)
+		q := NewQueueWithConfig[int](config)
+
+		res, err := q.AppendMany([]int{0, 1, 2, 3, 4})
+		testutil.AssertEqual(t, err, nil, "AppendMany() returned an unexpected error", false)
+		testutil.AssertDeepEqual(t, res.Offsets(), []uint64{math.MaxUint64 - 2, math.MaxUint64 - 1, math.MaxUint64, 0, 1}, "AppendMany() returned incorrect offsets across the wrap", false)
+
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 5, "incorrect Length() across the wrap", false)
+
+		removed, _ := q.Cleanup()
+		testutil.AssertEqual(t, removed, 1, "Cleanup() across the wrap deleted incorrect amount", false)
+
+		tx := BeginTx()
+		msgs, err := TxReadMany(tx, q, 2)
+		testutil.AssertEqual(t, err, nil, "TxReadMany() returned an unexpected error", false)
+		testutil.AssertEqual(t, msgs[1].Offset, uint64(math.MaxUint64), "TxReadMany() returned incorrect offset", false)
+		_ = TxAdd(tx, q, 5)
+		err = tx.Commit()
+		testutil.AssertEqual(t, err, nil, "Commit() across the wrap returned an unexpected error", false)
+
+		msgs, err = q.ReadMany(10)
+		testutil.AssertEqual(t, err, nil, "ReadMany() returned an unexpected error", false)
+		offsets := make([]uint64, len(msgs))
+		for i, msg := range msgs {
+			offsets[i] = msg.Offset
+		}
+		testutil.AssertDeepEqual(t, offsets, []uint64{0, 1, 2}, "ReadMany() returned incorrect offsets after the wrap", false)
+		testutil.AssertEqual(t, OffsetBefore(res.FirstOffset, msgs[0].Offset), true, "offset before the wrap is not before offset after the wrap", false)
+
+		flag, _ := q.IsEmpty()
+		testutil.AssertEqual(t, flag, true, "IsEmpty() returned an incorrect value after the wrap", false)
+
+		changed, _ := q.GetConfig().WithInitialOffset(0)
+		err = q.UpdateConfig(changed)
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "UpdateConfig() with a different initialOffset returned incorrect error", false)
+	})
 }
 
 func TestQueueConfig(t *testing.T) {
@@ -294,10 +527,23 @@ func TestQueueConfig(t *testing.T) {
 		testutil.AssertEqual(t, err, ErrInvalidConfig, "config.WithRetentionTime(time.Second * 0) returned an incorrect error", false)
 		_, err = config.WithRetentionTime(-time.Second)
 		testutil.AssertEqual(t, err, ErrInvalidConfig, "config.WithRetentionTime(-time.Second) returned an incorrect error", false)
+		_, err = config.WithDeduplicationWindow(-time.Second)
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "config.WithDeduplicationWindow(-time.Second) returned an incorrect error", false)
+		_, err = config.WithDeduplicationWindow(0)
+		testutil.AssertEqual(t, err, nil, "config.WithDeduplicationWindow(0) returned an unexpected error", false)
+		_, err = config.WithRetentionBytes(0)
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "config.WithRetentionBytes(0) returned an incorrect error", false)
+		_, err = config.WithSizeEstimator(nil)
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "config.WithSizeEstimator(nil) returned an incorrect error", false)
+
+		_, err = config.WithClock(nil)
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "config.WithClock(nil) returned an incorrect error", false)
 	})
 
 	t.Run("test Queue cleanups with QueueConfig parameters", func(t *testing.T) {
-		queueDefaultConfig := NewQueue[string]()
+		clock := testutil.NewFakeClock(time.Now())
+		configFakeClock, _ := DefaultConfig().WithClock(clock)
+		queueDefaultConfig := NewQueueWithConfig[string](configFakeClock)
 		configLowRetentionCount, _ := queueDefaultConfig.config.WithRetentionCount(1)
 		queueLowRetentionCount := NewQueueWithConfig[string](configLowRetentionCount)
 		configLowRetentionTime, _ := queueDefaultConfig.config.WithRetentionTime(time.Nanosecond)
@@ -313,7 +559,7 @@ func TestQueueConfig(t *testing.T) {
 		_ = queueLowRetentionCount.AddMany(vals)
 		_ = queueLowRetentionTime.AddMany(vals)
 		_ = queueLowRetentionCountAutoCleanup.AddMany(vals)
-		time.Sleep(time.Nanosecond * 5)
+		clock.Advance(time.Nanosecond * 5)
 
 		testTable := []struct {
 			name          string
@@ -339,5 +585,192 @@ func TestQueueConfig(t *testing.T) {
 			testutil.AssertEqual(t, got, test.finalLength, fmt.Sprintf("test %q has incorrect final length", test.name), false)
 		}
 
+		// Test that reading a queue whose messages are all cleaned up returns the correct error
+		configLowRetentionTimeAutoCleanup, _ := configLowRetentionTime.WithAutoCleanup(true)
+		queueLowRetentionTimeAutoCleanup := NewQueueWithConfig[string](configLowRetentionTimeAutoCleanup)
+		_ = queueLowRetentionTimeAutoCleanup.AddMany(vals)
+		clock.Advance(time.Nanosecond * 5)
+		_, err := queueLowRetentionTimeAutoCleanup.Read()
+		testutil.AssertEqual(t, err, ErrQueueIsEmpty, "Read() after all messages were cleaned up returned incorrect error", false)
+		_, err = queueLowRetentionTimeAutoCleanup.PeekNext()
+		testutil.AssertEqual(t, err, ErrQueueIsEmpty, "PeekNext() after all messages were cleaned up returned incorrect error", false)
 	})
+
+	t.Run("test UpdateConfig on a live queue", func(t *testing.T) {
+		config, _ := DefaultConfig().WithName("asd")
+		q := NewQueueWithConfig[string](config)
+		_ = q.AddMany([]string{"a", "b", "c"})
+
+		err := q.UpdateConfig(QueueConfig{name: "asd"})
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "UpdateConfig() with a manually created config returned incorrect error", false)
+
+		renamed, _ := config.WithName("dsa")
+		err = q.UpdateConfig(renamed)
+		testutil.AssertEqual(t, err, ErrInvalidConfig, "UpdateConfig() with a different name returned incorrect error", false)
+
+		lowRetentionCount, _ := config.WithRetentionCount(1)
+		err = q.UpdateConfig(lowRetentionCount)
+		testutil.AssertEqual(t, err, nil, "UpdateConfig() returned an unexpected error", false)
+		testutil.AssertEqual(t, q.GetConfig().retentionCount, 1, "UpdateConfig() did not change the config", false)
+
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 1, "UpdateConfig() with a lower retention count did not run cleanup, incorrect Length()", false)
+		msg, _ := q.Read()
+		testutil.AssertEqual(t, msg.Val, "c", "UpdateConfig() with a lower retention count removed incorrect messages", false)
+
+		_ = q.AddMany([]string{"a", "b"})
+		err = q.UpdateConfig(config)
+		testutil.AssertEqual(t, err, nil, "UpdateConfig() returned an unexpected error", false)
+		got, _ = q.Length()
+		testutil.AssertEqual(t, got, 2, "UpdateConfig() with a higher retention count removed messages, incorrect Length()", false)
+	})
+
+	t.Run("test Close() and Drain()", func(t *testing.T) {
+		q := NewQueue[string]()
+
+		waitErr := make(chan error)
+		go func() {
+			_, err := q.ReadWait(context.Background())
+			waitErr <- err
+		}()
+		err := q.Close()
+		testutil.AssertEqual(t, err, nil, "Close() returned an unexpected error", false)
+		testutil.AssertEqual(t, <-waitErr, ErrQueueClosed, "ReadWait() woken up by Close() returned incorrect error", false)
+
+		err = q.Add("asd")
+		testutil.AssertEqual(t, err, ErrQueueClosed, "Add() on a closed queue returned incorrect error", false)
+		_, err = q.AddManyWithIDs([]string{"id"}, []string{"asd"})
+		testutil.AssertEqual(t, err, ErrQueueClosed, "AddManyWithIDs() on a closed queue returned incorrect error", false)
+		err = q.Close()
+		testutil.AssertEqual(t, err, nil, "second Close() returned an unexpected error", false)
+
+		q = NewQueue[string]()
+		_ = q.AddMany([]string{"a", "b", "c"})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		err = q.Drain(ctx)
+		testutil.AssertEqual(t, err, context.DeadlineExceeded, "Drain() without consumers returned incorrect error", false)
+
+		msg, err := q.Read()
+		testutil.AssertEqual(t, err, nil, "Read() on a closed queue with messages returned an unexpected error", false)
+		testutil.AssertEqual(t, msg.Val, "a", "Read() on a closed queue returned incorrect value", false)
+
+		go func() {
+			for {
+				_, err := q.ReadWait(context.Background())
+				if err != nil {
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		err = q.Drain(ctx)
+		testutil.AssertEqual(t, err, nil, "Drain() with a consumer returned an unexpected error", false)
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 0, "queue is not empty after Drain(), incorrect Length()", false)
+	})
+
+	t.Run("test Queue cleanups with retentionBytes", func(t *testing.T) {
+		config, _ := DefaultConfig().WithRetentionBytes(10)
+		q := NewQueueWithConfig[string](config)
+
+		_ = q.AddMany([]string{"aaaa", "bbbb", "cccc"})
+		testutil.AssertEqual(t, q.bytes.Load(), 12, "incorrect total size of messages after AddMany()", false)
+
+		got, _ := q.Cleanup()
+		testutil.AssertEqual(t, got, 1, "Cleanup() with 12 bytes of messages and retentionBytes 10 deleted incorrect amount", false)
+		testutil.AssertEqual(t, q.bytes.Load(), 8, "incorrect total size of messages after Cleanup()", false)
+
+		msg, _ := q.Read()
+		testutil.AssertEqual(t, msg.Val, "bbbb", "Cleanup() by retentionBytes removed incorrect messages", false)
+		testutil.AssertEqual(t, q.bytes.Load(), 4, "incorrect total size of messages after Read()", false)
+
+		config, _ = config.WithSizeEstimator(func(val any) uint64 { return 100 })
+		config, _ = config.WithRetentionBytes(250)
+		q = NewQueueWithConfig[string](config)
+		_ = q.AddMany([]string{"a", "b", "c"})
+		got, _ = q.Cleanup()
+		testutil.AssertEqual(t, got, 1, "Cleanup() with a custom size estimator deleted incorrect amount", false)
+
+		lowRetentionBytes, _ := config.WithRetentionBytes(100)
+		_ = q.UpdateConfig(lowRetentionBytes)
+		length, _ := q.Length()
+		testutil.AssertEqual(t, length, 1, "UpdateConfig() with lower retentionBytes did not run cleanup, incorrect Length()", false)
+	})
+
+	t.Run("test sizes are only estimated with limited retentionBytes", func(t *testing.T) {
+		q := NewQueue[string]()
+		_ = q.AddMany([]string{"aaaa", "bbbb", "cccc"})
+		testutil.AssertEqual(t, q.bytes.Load(), 0, "sizes were estimated with unlimited retentionBytes", false)
+
+		config, _ := q.GetConfig().WithRetentionBytes(10)
+		_ = q.UpdateConfig(config)
+		testutil.AssertEqual(t, q.bytes.Load(), 8, "UpdateConfig() limiting retentionBytes did not estimate sizes, incorrect total size", false)
+		length, _ := q.Length()
+		testutil.AssertEqual(t, length, 2, "UpdateConfig() limiting retentionBytes did not run cleanup, incorrect Length()", false)
+
+		_ = q.Add("dd")
+		testutil.AssertEqual(t, q.bytes.Load(), 10, "incorrect total size of messages after Add()", false)
+
+		config.retentionBytes = math.MaxUint64
+		_ = q.UpdateConfig(config)
+		testutil.AssertEqual(t, q.bytes.Load(), 0, "UpdateConfig() with unlimited retentionBytes did not reset sizes", false)
+		msgs, _ := q.ReadMany(3)
+		testutil.AssertEqual(t, len(msgs), 3, "ReadMany() returned incorrect amount of messages", false)
+		testutil.AssertEqual(t, q.bytes.Load(), 0, "incorrect total size of messages after ReadMany()", false)
+	})
+
+	t.Run("test Queue with a zero-value QueueConfig", func(t *testing.T) {
+		q := NewQueueWithConfig[int](QueueConfig{})
+
+		res, err := q.AppendMany([]int{1, 2, 3})
+		testutil.AssertEqual(t, err, nil, "AppendMany() returned an unexpected error", false)
+		testutil.AssertEqual(t, res.LogAppendTime.IsZero(), false, "AppendMany() returned a zero LogAppendTime", false)
+
+		msgs, err := q.ReadMany(2)
+		testutil.AssertEqual(t, err, nil, "ReadMany() returned an unexpected error", false)
+		testutil.AssertEqual(t, len(msgs), 2, "ReadMany() returned incorrect amount of messages", false)
+
+		_, err = q.AddManyWithIDs([]string{"asd"}, []int{4})
+		testutil.AssertEqual(t, err, nil, "AddManyWithIDs() returned an unexpected error", false)
+		_, err = q.AddManyIdempotent("producer", 0, []int{5})
+		testutil.AssertEqual(t, err, nil, "AddManyIdempotent() returned an unexpected error", false)
+
+		// A zero retentionCount keeps no messages in cleanup.
+		removed, err := q.Cleanup()
+		testutil.AssertEqual(t, err, nil, "Cleanup() returned an unexpected error", false)
+		testutil.AssertEqual(t, removed, 3, "Cleanup() deleted incorrect amount", false)
+	})
+
+	t.Run("test NewQueueWithConfig fills in a missing sizeEstimator and retentionBytes", func(t *testing.T) {
+		config := DefaultConfig()
+		config.sizeEstimator = nil
+		config.retentionBytes = 0
+		q := NewQueueWithConfig[int](config)
+
+		err := q.Add(1)
+		testutil.AssertEqual(t, err, nil, "Add() returned an unexpected error", false)
+		msg, err := q.Read()
+		testutil.AssertEqual(t, err, nil, "Read() returned an unexpected error", false)
+		testutil.AssertEqual(t, msg.Val, 1, "Read() returned incorrect value", false)
+		testutil.AssertEqual(t, q.GetConfig().validate(), nil, "config of the queue is invalid", false)
+	})
+
+	t.Run("test EstimateSize", func(t *testing.T) {
+		testutil.AssertEqual(t, EstimateSize("asd"), 3, "EstimateSize() of a string returned incorrect size", false)
+		testutil.AssertEqual(t, EstimateSize([]byte{1, 2}), 2, "EstimateSize() of a byte slice returned incorrect size", false)
+		testutil.AssertEqual(t, EstimateSize(int64(1)), 8, "EstimateSize() of an int64 returned incorrect size", false)
+		testutil.AssertEqual(t, EstimateSize(nil), 0, "EstimateSize() of nil returned incorrect size", false)
+		testutil.AssertEqual(t, EstimateSize(testSizer(42)), 42, "EstimateSize() of a Sizer returned incorrect size", false)
+	})
+}
+
+// Sizer whose size is the value itself.
+type testSizer int
+
+func (s testSizer) Size() int {
+	return int(s)
 }
@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// How many replies a Requester reads from its reply Queue at once.
+const replyBatchSize = 100
+
+// Request[Req, Resp] is a message sent with a Requester. It contains the
+// actual request in Body, a CorrelationID to match the reply to the request,
+// and the Queue the reply should be added to.
+type Request[Req, Resp any] struct {
+	CorrelationID string
+	ReplyTo       *Queue[Reply[Resp]]
+	Body          Req
+}
+
+// Reply[Resp] is a reply to a Request. It contains the CorrelationID of the
+// Request and either the actual reply in Body or an error message in Err.
+type Reply[Resp any] struct {
+	CorrelationID string
+	Body          Resp
+	Err           string
+}
+
+// Handler[Req, Resp] is a function a Responder calls to handle a request.
+type Handler[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// Requester[Req, Resp] sends requests of type Req to a Queue and waits for
+// replies of type Resp. Every Requester has its own private reply Queue.
+//
+// Requester methods are safe to use concurrently in multiple goroutines.
+//
+// NOTE: never create a Requester directly; use NewRequester instead.
+type Requester[Req, Resp any] struct {
+	requests *Queue[Request[Req, Resp]]
+	replies  *Queue[Reply[Resp]]
+	pending  map[string]chan Reply[Resp]
+	mu       sync.Mutex
+}
+
+// Responder[Req, Resp] reads requests of type Req from a Queue, handles them
+// with a Handler, and adds the replies to the reply Queues of the requests.
+//
+// NOTE: never create a Responder directly; use NewResponder instead.
+type Responder[Req, Resp any] struct {
+	requests *Queue[Request[Req, Resp]]
+	handler  Handler[Req, Resp]
+}
+
+// Function to create a new Requester that sends requests to the Queue requests.
+func NewRequester[Req, Resp any](requests *Queue[Request[Req, Resp]]) *Requester[Req, Resp] {
+	r := Requester[Req, Resp]{
+		requests: requests,
+		replies:  NewQueue[Reply[Resp]](),
+		pending:  make(map[string]chan Reply[Resp]),
+	}
+	return &r
+}
+
+// Method to send a request and wait for the correlated reply.
+//
+// If ctx is done before the reply arrives, returns the error of ctx,
+// e.g. context.DeadlineExceeded. If the Handler of the Responder returned
+// an error, returns an error wrapping ErrRequestFailed.
+func (r *Requester[Req, Resp]) Request(ctx context.Context, req Req) (Resp, error) {
+	var zero Resp
+	id, err := newCorrelationID()
+	if err != nil {
+		return zero, err
+	}
+
+	ch := make(chan Reply[Resp], 1)
+	r.mu.Lock()
+	r.pending[id] = ch
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}()
+
+	err = r.requests.Add(Request[Req, Resp]{
+		CorrelationID: id,
+		ReplyTo:       r.replies,
+		Body:          req,
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	for {
+		// Get the channel before dispatching so that no reply added
+		// in between is missed.
+		notify := r.replies.changed()
+		r.dispatchReplies()
+		select {
+		case reply := <-ch:
+			if reply.Err != "" {
+				return zero, fmt.Errorf("%w: %s", ErrRequestFailed, reply.Err)
+			}
+			return reply.Body, nil
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// Internal method to read replies from the reply Queue and deliver them
+// to the pending requests. Replies to requests that are no longer
+// pending, e.g. because they timed out, are discarded.
+func (r *Requester[Req, Resp]) dispatchReplies() {
+	replies, err := r.replies.ReadMany(replyBatchSize)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, msg := range replies {
+		if ch, ok := r.pending[msg.Val.CorrelationID]; ok {
+			ch <- msg.Val
+			delete(r.pending, msg.Val.CorrelationID)
+		}
+	}
+}
+
+// Function to create a new Responder that handles the requests in the Queue
+// requests with handler.
+func NewResponder[Req, Resp any](requests *Queue[Request[Req, Resp]], handler Handler[Req, Resp]) *Responder[Req, Resp] {
+	r := Responder[Req, Resp]{
+		requests: requests,
+		handler:  handler,
+	}
+	return &r
+}
+
+// Method to handle requests until ctx is done or the request Queue is
+// closed and empty. Returns the error of ctx or ErrQueueClosed.
+func (r *Responder[Req, Resp]) Serve(ctx context.Context) error {
+	for {
+		msg, err := r.requests.ReadWait(ctx)
+		if err != nil {
+			return err
+		}
+		if err := r.handle(ctx, msg.Val); err != nil {
+			return err
+		}
+	}
+}
+
+// Method to handle a single request if there is one in the Queue.
+// Returns true if a request was handled.
+func (r *Responder[Req, Resp]) HandleNext(ctx context.Context) (bool, error) {
+	msg, err := r.requests.Read()
+	if err == ErrQueueIsEmpty {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, r.handle(ctx, msg.Val)
+}
+
+// Internal method to handle a request and send the reply.
+//
+// Errors returned by the Handler are sent to the requester in the reply.
+// Requests without a reply Queue are handled and the reply is discarded.
+func (r *Responder[Req, Resp]) handle(ctx context.Context, req Request[Req, Resp]) error {
+	resp, err := r.handler(ctx, req.Body)
+	reply := Reply[Resp]{
+		CorrelationID: req.CorrelationID,
+		Body:          resp,
+	}
+	if err != nil {
+		reply.Err = err.Error()
+	}
+	if req.ReplyTo != nil {
+		return req.ReplyTo.Add(reply)
+	}
+	return nil
+}
+
+// Returns a new random correlation ID.
+func newCorrelationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,83 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+func TestRequestReply(t *testing.T) {
+	t.Run("test concurrent requests get their correlated replies", func(t *testing.T) {
+		requests := NewQueue[Request[int, string]]()
+		requester := NewRequester(requests)
+		responder := NewResponder(requests, func(ctx context.Context, req int) (string, error) {
+			return strconv.Itoa(req), nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go responder.Serve(ctx)
+
+		const n = 100
+		var wg sync.WaitGroup
+		got := make([]string, n)
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(index int) {
+				reqCtx, reqCancel := context.WithTimeout(ctx, time.Second*5)
+				defer reqCancel()
+				got[index], errs[index] = requester.Request(reqCtx, index)
+				wg.Done()
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < n; i++ {
+			testutil.AssertEqual(t, errs[i], nil, "Request() returned an unexpected error", true)
+			testutil.AssertEqual(t, got[i], strconv.Itoa(i), "Request() returned an incorrect reply", false)
+		}
+	})
+
+	t.Run("test handler errors are returned to the requester", func(t *testing.T) {
+		requests := NewQueue[Request[int, int]]()
+		requester := NewRequester(requests)
+		responder := NewResponder(requests, func(ctx context.Context, req int) (int, error) {
+			return 0, errors.New("bad request")
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		go responder.Serve(ctx)
+
+		_, err := requester.Request(ctx, 1)
+		testutil.AssertEqual(t, errors.Is(err, ErrRequestFailed), true, "Request() with a failing handler did not return ErrRequestFailed", false)
+	})
+
+	t.Run("test request without a responder times out", func(t *testing.T) {
+		requests := NewQueue[Request[int, int]]()
+		requester := NewRequester(requests)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+
+		_, err := requester.Request(ctx, 1)
+		testutil.AssertEqual(t, err, context.DeadlineExceeded, "Request() without a responder returned incorrect error", false)
+
+		// The late reply to the timed out request is discarded.
+		responder := NewResponder(requests, func(ctx context.Context, req int) (int, error) {
+			return req, nil
+		})
+		handled, err := responder.HandleNext(context.Background())
+		testutil.AssertEqual(t, err, nil, "HandleNext() returned an unexpected error", false)
+		testutil.AssertEqual(t, handled, true, "HandleNext() did not handle the pending request", false)
+		requester.dispatchReplies()
+		got, _ := requester.replies.Length()
+		testutil.AssertEqual(t, got, 0, "late reply was not discarded, incorrect Length()", false)
+	})
+}
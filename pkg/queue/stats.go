@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"slices"
+	"time"
+)
+
+// Upper bounds of the read latency histogram buckets.
+var readLatencyBounds = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+}
+
+// Stats type contains statistics of a Queue at a point in time.
+//
+// Appended, Consumed, and Removed are counted from the creation of the
+// Queue, so add and read rates can be computed from the change in
+// Appended and Consumed between two Stats.
+type Stats struct {
+	Name   string
+	Length uint64
+
+	// Estimated total size of the messages in the Queue in bytes;
+	// only estimated if the retentionBytes of the Queue is limited.
+	Bytes uint64
+
+	// Total count of messages added to, read from, and removed by
+	// cleanup from the Queue.
+	Appended uint64
+	Consumed uint64
+	Removed  uint64
+
+	// Time since the LogAppendTime of the oldest message in the Queue;
+	// 0 if the Queue is empty.
+	OldestAge time.Duration
+
+	// Distribution of the time from the LogAppendTime of a message to
+	// the time it was read.
+	ReadLatency Histogram
+}
+
+// Histogram type contains a distribution of durations.
+// Counts[i] is the count of durations less than or equal to Bounds[i],
+// so the counts are cumulative. Count and Sum are the total count and
+// the sum of all durations.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Distribution of read latencies. Used for Queue internals.
+type latencyHistogram struct {
+	counts [len(readLatencyBounds)]uint64
+	count  uint64
+	sum    time.Duration
+}
+
+// Internal method to add a duration to the histogram.
+// Negative durations, e.g. from a clock set backwards, count as 0.
+func (h *latencyHistogram) observe(d time.Duration) {
+	d = max(d, 0)
+	for i, bound := range readLatencyBounds {
+		if d <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += d
+}
+
+// Internal method to get the histogram with cumulative counts.
+func (h *latencyHistogram) histogram() Histogram {
+	res := Histogram{
+		Bounds: slices.Clone(readLatencyBounds[:]),
+		Counts: make([]uint64, len(readLatencyBounds)),
+		Count:  h.count,
+		Sum:    h.sum,
+	}
+	cumulative := uint64(0)
+	for i, count := range h.counts {
+		cumulative += count
+		res.Counts[i] = cumulative
+	}
+	return res
+}
+
+// Method to get the statistics of the Queue.
+func (q *Queue[T]) Stats() (Stats, error) {
+	q.headMu.Lock()
+	defer q.headMu.Unlock()
+
+	if !q.isProperlyInitialized() {
+		return Stats{}, ErrImproperlyInitializedQueue
+	}
+
+	if q.config.autoCleanup {
+		q.cleanup()
+	}
+
+	tailOffset := q.tailOffset.Load()
+	res := Stats{
+		Name:        q.config.name,
+		Length:      tailOffset - q.headOffset,
+		Bytes:       q.bytesNoLock(),
+		Appended:    tailOffset - q.config.initialOffset,
+		Consumed:    q.headOffset - q.config.initialOffset - q.removed,
+		Removed:     q.removed,
+		ReadLatency: q.readLatency.histogram(),
+	}
+	if res.Length > 0 {
+		res.OldestAge = max(q.config.clock.Now().Sub(q.peekNoLock().LogAppendTime), 0)
+	}
+	return res, nil
+}
@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+func TestStats(t *testing.T) {
+	t.Run("test calling Stats() on a manually initialized Queue returns correct error", func(t *testing.T) {
+		q := Queue[string]{}
+
+		_, err := q.Stats()
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "Stats() on a manually created queue returned incorrect error", false)
+	})
+
+	t.Run("test Stats() counts and ages", func(t *testing.T) {
+		clock := testutil.NewFakeClock(time.Now())
+		config, _ := DefaultConfig().WithName("asd")
+		config, _ = config.WithClock(clock)
+		config, _ = config.WithRetentionCount(3)
+		config, _ = config.WithInitialOffset(10)
+		q := NewQueueWithConfig[string](config)
+
+		stats, err := q.Stats()
+		testutil.AssertEqual(t, err, nil, "Stats() returned an unexpected error", false)
+		testutil.AssertEqual(t, stats.Name, "asd", "Stats() of an empty queue has incorrect Name", false)
+		testutil.AssertEqual(t, stats.Appended, 0, "Stats() of an empty queue has incorrect Appended", false)
+		testutil.AssertEqual(t, stats.OldestAge, 0, "Stats() of an empty queue has incorrect OldestAge", false)
+
+		_ = q.AddMany([]string{"a", "b", "c", "d"})
+		clock.Advance(time.Millisecond * 3)
+		_ = q.Add("e")
+		clock.Advance(time.Millisecond * 4)
+
+		_, _ = q.Cleanup()
+		_, _ = q.Read()
+		tx := BeginTx()
+		_, _ = TxRead(tx, q)
+		_ = tx.Commit()
+
+		stats, _ = q.Stats()
+		testutil.AssertEqual(t, stats.Length, 1, "Stats() has incorrect Length", false)
+		testutil.AssertEqual(t, stats.Appended, 5, "Stats() has incorrect Appended", false)
+		testutil.AssertEqual(t, stats.Consumed, 2, "Stats() has incorrect Consumed", false)
+		testutil.AssertEqual(t, stats.Removed, 2, "Stats() has incorrect Removed", false)
+		testutil.AssertEqual(t, stats.OldestAge, time.Millisecond*4, "Stats() has incorrect OldestAge", false)
+
+		h := stats.ReadLatency
+		testutil.AssertEqual(t, h.Count, 2, "read latency histogram has incorrect Count", false)
+		testutil.AssertEqual(t, h.Sum, time.Millisecond*14, "read latency histogram has incorrect Sum", false)
+		testutil.AssertEqual(t, len(h.Counts), len(h.Bounds), "read latency histogram has incorrect amount of buckets", false)
+		testutil.AssertEqual(t, h.Counts[0], 0, "read latency histogram has incorrect count in the 1ms bucket", false)
+		testutil.AssertEqual(t, h.Counts[1], 0, "read latency histogram has incorrect count in the 5ms bucket", false)
+		testutil.AssertEqual(t, h.Counts[2], 2, "read latency histogram has incorrect count in the 10ms bucket", false)
+		testutil.AssertEqual(t, h.Counts[len(h.Counts)-1], 2, "read latency histogram has incorrect count in the last bucket", false)
+	})
+}
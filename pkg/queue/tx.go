@@ -0,0 +1,285 @@
+package queue
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+)
+
+// Tx is a transaction spanning multiple Queues, possibly with different
+// message types. Within a Tx, messages can be added to Queues with TxAdd
+// and TxAddMany, and consumed from Queues with TxRead and TxReadMany.
+// Nothing is visible to other users of the Queues until Commit is called,
+// at which point all adds and reads of the Tx are applied atomically.
+//
+// Reads of a Tx do not remove messages from the Queue before Commit, so
+// other consumers can still read them. If any message consumed in a Tx has
+// been consumed or cleaned up from the Queue before Commit, the Tx fails
+// with the error ErrTxConflict and nothing is applied.
+//
+// Tx methods are safe to use concurrently in multiple goroutines.
+//
+// NOTE: never create a Tx directly; use BeginTx() instead.
+type Tx struct {
+	mu     sync.Mutex
+	done   bool
+	queues map[uint64]txQueue
+}
+
+// Pending operations of a Tx on a single Queue. Used for Tx internals.
+type txQueue interface {
+	queueID() uint64
+	lock()
+	unlock()
+	validate() error
+	apply()
+	afterCommit()
+}
+
+// Pending operations of a Tx on a Queue[T]. Used for Tx internals.
+//
+// readStart is the head offset of the Queue when the Tx first consumed
+// from it, and readEnd is the offset of the first message the Tx has not
+// consumed.
+type txQueueOps[T any] struct {
+	q         *Queue[T]
+	adds      []T
+	reading   bool
+	readStart uint64
+	readEnd   uint64
+}
+
+// Function to begin a new transaction.
+func BeginTx() *Tx {
+	tx := Tx{
+		queues: make(map[uint64]txQueue),
+	}
+	return &tx
+}
+
+// Internal function to get the pending operations of tx on q.
+// Does not lock tx; assumes that tx is already locked when this
+// function is called.
+func txOps[T any](tx *Tx, q *Queue[T]) (*txQueueOps[T], error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	if !q.isProperlyInitialized() {
+		return nil, ErrImproperlyInitializedQueue
+	}
+	if ops, ok := tx.queues[q.id]; ok {
+		return ops.(*txQueueOps[T]), nil
+	}
+	ops := txQueueOps[T]{
+		q: q,
+	}
+	tx.queues[q.id] = &ops
+	return &ops, nil
+}
+
+// Function to add a single message to q in tx.
+func TxAdd[T any](tx *Tx, q *Queue[T], val T) error {
+	return TxAddMany(tx, q, []T{val})
+}
+
+// Function to add multiple messages to q in tx.
+// The messages are added to q when tx is committed.
+//
+// If tx has already been committed or aborted, returns the error ErrTxDone.
+func TxAddMany[T any](tx *Tx, q *Queue[T], vals []T) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	ops, err := txOps(tx, q)
+	if err != nil {
+		return err
+	}
+	ops.adds = append(ops.adds, vals...)
+	return nil
+}
+
+// Function to read a single message from q in tx.
+func TxRead[T any](tx *Tx, q *Queue[T]) (Message[T], error) {
+	res, err := TxReadMany(tx, q, 1)
+	if err != nil {
+		return Message[T]{}, err
+	}
+	return res[0], nil
+}
+
+// Function to read multiple messages from q in tx.
+// Reads at most `limit` messages following the ones already read in tx.
+// The messages are consumed from q when tx is committed.
+//
+// If `limit` is non-positive, returns the error ErrInvalidLimit.
+// If there are no more messages in q, returns the error ErrQueueIsEmpty.
+// If the messages already read in tx have been consumed from q, returns
+// the error ErrTxConflict.
+func TxReadMany[T any](tx *Tx, q *Queue[T], limit int) ([]Message[T], error) {
+	if limit <= 0 {
+		return []Message[T]{}, ErrInvalidLimit
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	ops, err := txOps(tx, q)
+	if err != nil {
+		return []Message[T]{}, err
+	}
+
+	q.headMu.Lock()
+	defer q.headMu.Unlock()
+
+	// The Tx only starts reading from q when messages are returned, so
+	// reading an empty Queue does not make the Tx conflict with others.
+	readEnd := ops.readEnd
+	if !ops.reading {
+		if q.config.autoCleanup {
+			q.cleanup()
+		}
+		readEnd = q.headOffset
+	} else if q.headOffset != ops.readStart {
+		return []Message[T]{}, ErrTxConflict
+	}
+
+	read := readEnd - q.headOffset
+	available := q.lengthNoLock() - read
+	if available == 0 {
+		return []Message[T]{}, ErrQueueIsEmpty
+	}
+	if !ops.reading {
+		ops.reading = true
+		ops.readStart = q.headOffset
+	}
+	if available <= math.MaxInt {
+		limit = min(limit, int(available))
+	}
+	res := make([]Message[T], limit)
+	pos := q.positionNoLock(read)
+	for i := 0; i < limit; i++ {
+		res[i] = pos.chunk.messages[pos.index]
+		pos.advance()
+	}
+	ops.readEnd = readEnd + uint64(limit)
+	return res, nil
+}
+
+// Method to atomically apply all adds and reads of the Tx.
+//
+// If any message read in the Tx has been consumed from its Queue,
+// returns the error ErrTxConflict and applies nothing. If the Tx adds
+// messages to a closed Queue, returns the error ErrQueueClosed and
+// applies nothing.
+// If the Tx has already been committed or aborted, returns the error ErrTxDone.
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	// Lock the Queues in the order of their ids so that concurrent
+	// commits cannot deadlock.
+	queues := make([]txQueue, 0, len(tx.queues))
+	for _, ops := range tx.queues {
+		queues = append(queues, ops)
+	}
+	slices.SortFunc(queues, func(a, b txQueue) int {
+		return cmp.Compare(a.queueID(), b.queueID())
+	})
+	// Deferred before the unlocks so that it runs after the Queues
+	// are unlocked.
+	committed := false
+	defer func() {
+		if committed {
+			for _, ops := range queues {
+				ops.afterCommit()
+			}
+		}
+	}()
+	for _, ops := range queues {
+		ops.lock()
+		defer ops.unlock()
+	}
+
+	for _, ops := range queues {
+		if err := ops.validate(); err != nil {
+			return err
+		}
+	}
+	for _, ops := range queues {
+		ops.apply()
+	}
+	committed = true
+	return nil
+}
+
+// Method to discard all adds and reads of the Tx.
+// Aborting a Tx that has already been committed or aborted does nothing.
+func (tx *Tx) Abort() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.done = true
+	tx.queues = nil
+}
+
+func (ops *txQueueOps[T]) queueID() uint64 {
+	return ops.q.id
+}
+
+func (ops *txQueueOps[T]) lock() {
+	ops.q.lockAll()
+}
+
+func (ops *txQueueOps[T]) unlock() {
+	ops.q.unlockAll()
+}
+
+// Checks that no message read in the Tx has been consumed from the Queue
+// and that the Queue is not closed if the Tx adds messages to it.
+// Assumes that the Queue is already locked when this function is called.
+func (ops *txQueueOps[T]) validate() error {
+	if ops.reading && ops.q.headOffset != ops.readStart {
+		return ErrTxConflict
+	}
+	if len(ops.adds) > 0 && ops.q.closed {
+		return ErrQueueClosed
+	}
+	return nil
+}
+
+// Consumes the messages read in the Tx and adds the messages added in the Tx.
+// Assumes that the Queue is already locked when this function is called.
+func (ops *txQueueOps[T]) apply() {
+	if ops.reading && ops.q.headOffset != ops.readEnd {
+		readTime := ops.q.config.clock.Now()
+		for ops.q.headOffset != ops.readEnd {
+			ops.q.readLatency.observe(readTime.Sub(ops.q.peekNoLock().LogAppendTime))
+			ops.q.popNoLock()
+		}
+		ops.q.signal()
+	}
+	ops.q.addManyNoLock(ops.adds, ops.q.config.clock.Now())
+
+	if ops.q.config.autoCleanup {
+		ops.q.cleanup()
+	}
+}
+
+// Checks the lag of the Queue if the Tx added messages to it or
+// consumed messages from it.
+// Assumes that the Queue is not locked when this function is called.
+func (ops *txQueueOps[T]) afterCommit() {
+	if len(ops.adds) == 0 && !ops.reading {
+		return
+	}
+	ops.q.headMu.Lock()
+	check := ops.q.checkLagNoLock()
+	ops.q.headMu.Unlock()
+
+	ops.q.notifyLag(check)
+}
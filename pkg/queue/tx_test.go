@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/VillePuuska/Message-queue/internal/testutil"
+)
+
+func TestTx(t *testing.T) {
+	t.Run("test transactional functions on a manually initialized Queue return correct errors", func(t *testing.T) {
+		q := Queue[string]{}
+		tx := BeginTx()
+
+		err := TxAdd(tx, &q, "asd")
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "TxAdd() on a manually created queue returned incorrect error", false)
+
+		_, err = TxRead(tx, &q)
+		testutil.AssertEqual(t, err, ErrImproperlyInitializedQueue, "TxRead() on a manually created queue returned incorrect error", false)
+	})
+
+	t.Run("test uncommitted messages are invisible and committed messages are added", func(t *testing.T) {
+		q1 := NewQueue[string]()
+		q2 := NewQueue[int]()
+		tx := BeginTx()
+
+		err := TxAddMany(tx, q1, []string{"asd", "dsa"})
+		testutil.AssertEqual(t, err, nil, "TxAddMany() returned an unexpected error", false)
+		err = TxAdd(tx, q2, 123)
+		testutil.AssertEqual(t, err, nil, "TxAdd() returned an unexpected error", false)
+
+		_, err = q1.PeekNext()
+		testutil.AssertEqual(t, err, ErrQueueIsEmpty, "uncommitted messages were visible to PeekNext()", false)
+		_, err = q2.Read()
+		testutil.AssertEqual(t, err, ErrQueueIsEmpty, "uncommitted messages were visible to Read()", false)
+
+		err = tx.Commit()
+		testutil.AssertEqual(t, err, nil, "Commit() returned an unexpected error", false)
+
+		got, _ := q1.Length()
+		testutil.AssertEqual(t, got, 2, "incorrect Length() after Commit()", false)
+		msg, _ := q2.Read()
+		testutil.AssertEqual(t, msg.Val, 123, "Read() after Commit() returned incorrect value", false)
+
+		err = tx.Commit()
+		testutil.AssertEqual(t, err, ErrTxDone, "second Commit() returned incorrect error", false)
+		err = TxAdd(tx, q2, 321)
+		testutil.AssertEqual(t, err, ErrTxDone, "TxAdd() after Commit() returned incorrect error", false)
+	})
+
+	t.Run("test read-process-write commit and abort", func(t *testing.T) {
+		in := NewQueue[int]()
+		out := NewQueue[int]()
+		_ = in.AddMany([]int{1, 2, 3})
+
+		tx := BeginTx()
+		msgs, err := TxReadMany(tx, in, 2)
+		testutil.AssertEqual(t, err, nil, "TxReadMany() returned an unexpected error", false)
+		testutil.AssertEqual(t, len(msgs), 2, "TxReadMany() returned incorrect amount of messages", false)
+		msg, err := TxRead(tx, in)
+		testutil.AssertEqual(t, err, nil, "TxRead() returned an unexpected error", false)
+		testutil.AssertEqual(t, msg.Val, 3, "TxRead() did not continue after the messages already read in the transaction", false)
+		_, err = TxRead(tx, in)
+		testutil.AssertEqual(t, err, ErrQueueIsEmpty, "TxRead() after reading all messages returned incorrect error", false)
+		_ = TxAdd(tx, out, msgs[0].Val*10)
+		tx.Abort()
+
+		got, _ := in.Length()
+		testutil.AssertEqual(t, got, 3, "aborted transaction consumed messages, incorrect Length()", false)
+		got, _ = out.Length()
+		testutil.AssertEqual(t, got, 0, "aborted transaction added messages, incorrect Length()", false)
+
+		tx = BeginTx()
+		msg, _ = TxRead(tx, in)
+		_ = TxAdd(tx, out, msg.Val*10)
+		err = tx.Commit()
+		testutil.AssertEqual(t, err, nil, "Commit() returned an unexpected error", false)
+
+		got, _ = in.Length()
+		testutil.AssertEqual(t, got, 2, "committed transaction did not consume messages, incorrect Length()", false)
+		msg, _ = out.Read()
+		testutil.AssertEqual(t, msg.Val, 10, "committed transaction added incorrect value", false)
+	})
+
+	t.Run("test reading an empty queue does not conflict", func(t *testing.T) {
+		q := NewQueue[int]()
+
+		tx := BeginTx()
+		_, err := TxRead(tx, q)
+		testutil.AssertEqual(t, err, ErrQueueIsEmpty, "TxRead() on an empty queue returned incorrect error", false)
+
+		_ = q.AddMany([]int{1, 2, 3})
+		_, _ = q.Read()
+
+		msg, err := TxRead(tx, q)
+		testutil.AssertEqual(t, err, nil, "TxRead() after another consumer read the queue returned an unexpected error", false)
+		testutil.AssertEqual(t, msg.Val, 2, "TxRead() returned incorrect value", false)
+
+		err = tx.Commit()
+		testutil.AssertEqual(t, err, nil, "Commit() after an empty TxRead() returned an unexpected error", false)
+		got, _ := q.Length()
+		testutil.AssertEqual(t, got, 1, "committed transaction consumed incorrect amount of messages, incorrect Length()", false)
+	})
+
+	t.Run("test conflicting consumers", func(t *testing.T) {
+		in := NewQueue[int]()
+		out := NewQueue[int]()
+		_ = in.AddMany([]int{1, 2})
+
+		tx := BeginTx()
+		_, _ = TxRead(tx, in)
+		_ = TxAdd(tx, out, 10)
+
+		_, _ = in.Read()
+		_, err := TxRead(tx, in)
+		testutil.AssertEqual(t, err, ErrTxConflict, "TxRead() after another consumer read the queue returned incorrect error", false)
+
+		err = tx.Commit()
+		testutil.AssertEqual(t, err, ErrTxConflict, "Commit() after another consumer read the queue returned incorrect error", false)
+
+		got, _ := out.Length()
+		testutil.AssertEqual(t, got, 0, "conflicting transaction added messages, incorrect Length()", false)
+		got, _ = in.Length()
+		testutil.AssertEqual(t, got, 1, "conflicting transaction consumed messages, incorrect Length()", false)
+	})
+
+	t.Run("test committing adds to a closed queue", func(t *testing.T) {
+		in := NewQueue[int]()
+		out := NewQueue[int]()
+		_ = in.Add(1)
+
+		tx := BeginTx()
+		_, _ = TxRead(tx, in)
+		_ = TxAdd(tx, out, 10)
+		_ = out.Close()
+
+		err := tx.Commit()
+		testutil.AssertEqual(t, err, ErrQueueClosed, "Commit() adding to a closed queue returned incorrect error", false)
+		got, _ := in.Length()
+		testutil.AssertEqual(t, got, 1, "failed transaction consumed messages, incorrect Length()", false)
+	})
+}